@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// permission is what a client of the api is allowed to do.
+type permission int
+
+const (
+	permissionNone permission = iota
+	permissionRead
+	permissionControl
+)
+
+// paused is set by the control api, cycles are skipped while it is.
+var paused int32
+
+func isPaused() bool {
+	return atomic.LoadInt32(&paused) == 1
+}
+
+// authenticated tells if the api requires clients to authenticate.
+func (o *options) authenticated() bool {
+	return len(o.apiTokens) > 0 || len(o.controlTokens) > 0 || o.tlsClientCA != ""
+}
+
+// permissionOf returns the permission of the request: control for a control
+// token or a verified client certificate, read for a read token. Requests
+// are granted read when the api is not authenticated.
+func permissionOf(r *http.Request, opts *options) permission {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return permissionControl
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token != "" {
+		for _, t := range opts.controlTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return permissionControl
+			}
+		}
+		for _, t := range opts.apiTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return permissionRead
+			}
+		}
+	}
+	if !opts.authenticated() {
+		return permissionRead
+	}
+	return permissionNone
+}
+
+// require only lets requests with at least the permission through.
+func require(p permission, opts *options, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		granted := permissionOf(r, opts)
+		if granted >= p {
+			handler(w, r)
+			return
+		}
+		logrus.WithField("path", r.URL.Path).WithField("remote", r.RemoteAddr).Warn("unauthorized api request")
+		if granted == permissionNone {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}
+}
+
+// pauseHandler pauses, or resumes, the cycles.
+func pauseHandler(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if pause {
+			atomic.StoreInt32(&paused, 1)
+			logrus.Info("cycles paused by api")
+		} else {
+			atomic.StoreInt32(&paused, 0)
+			logrus.Info("cycles resumed by api")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// tlsConfig is the server tls config, requiring client certificates signed
+// by the client ca when one is given.
+func tlsConfig(opts *options) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.tlsClientCA != "" {
+		data, err := ioutil.ReadFile(opts.tlsClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in client ca %s", opts.tlsClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
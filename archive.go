@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types"
+)
+
+// archiveLogs saves the whole logs of the container to a file of dir named
+// after the cycle and the container.
+func archiveLogs(container candidate, cycle int, dir string) (string, error) {
+	ctx := context.Background()
+	client := container.host.client
+	infos, err := client.ContainerInspect(ctx, container.ID)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect container id %s: %w", container.ID, err)
+	}
+	logs, err := client.ContainerLogs(ctx, container.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not get logs of container id %s: %w", container.ID, err)
+	}
+	defer logs.Close()
+	var r io.Reader = logs
+	if infos.Config == nil || !infos.Config.Tty {
+		r = demuxLogs(logs)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("cycle-%06d-%s.log", cycle, shortID(container.ID)))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("could not create log archive: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil && err != io.EOF {
+		f.Close()
+		return "", fmt.Errorf("could not archive logs of container id %s: %w", container.ID, err)
+	}
+	return path, f.Close()
+}
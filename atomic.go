@@ -0,0 +1,38 @@
+package main
+
+import "github.com/sirupsen/logrus"
+
+// created is a copy created by a cycle.
+type created struct {
+	host     *host
+	id       string
+	isolated string
+}
+
+// rollback removes the copies created by a cycle which failed and recreates
+// the victims it already removed from their specs, for atomic cycles to
+// either fully succeed or change nothing. It returns the victims which could
+// not be recreated.
+func rollback(log *logrus.Entry, copies []created, removed []candidate, specs map[string]copySpec, opts *options) []candidate {
+	for _, c := range copies {
+		discardContainer(log, c.host, c.id, c.isolated)
+	}
+	lost := []candidate{}
+	for _, victim := range removed {
+		spec, ok := specs[victim.ID]
+		if !ok {
+			lost = append(lost, victim)
+			continue
+		}
+		if _, err := recreateContainer(log, victim.host, victim.ID, spec, opts); err != nil {
+			log.WithError(err).WithField("container", victim.ID).WithField("host", victim.host.name).Error("could not recreate victim")
+			lost = append(lost, victim)
+		}
+	}
+	if len(lost) > 0 {
+		log.WithField("copies", len(copies)).WithField("lost", len(lost)).Error("cycle partially rolled back")
+	} else {
+		log.WithField("copies", len(copies)).WithField("victims", len(removed)).Warn("cycle rolled back")
+	}
+	return lost
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imdsAddr is the address of the ec2 instance metadata service.
+const imdsAddr = "http://169.254.169.254"
+
+// awsCredentialsProvider resolves aws credentials from the same sources, in
+// the same order, as the default chain of the aws sdks: the environment, the
+// shared credentials file, the ecs container credentials endpoint and the
+// ec2 instance role. Expiring credentials are refreshed before they expire.
+type awsCredentialsProvider struct {
+	mu     sync.Mutex
+	creds  awsCredentials
+	client *http.Client
+}
+
+func newAWSCredentialsProvider() *awsCredentialsProvider {
+	return &awsCredentialsProvider{client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (p *awsCredentialsProvider) get() (awsCredentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds.accessKey != "" && (p.creds.expires.IsZero() || time.Until(p.creds.expires) > 5*time.Minute) {
+		return p.creds, nil
+	}
+	for _, source := range []func() (awsCredentials, error){p.fromEnv, p.fromSharedFile, p.fromContainer, p.fromInstance} {
+		creds, err := source()
+		if err != nil {
+			return creds, err
+		}
+		if creds.accessKey != "" {
+			p.creds = creds
+			return creds, nil
+		}
+	}
+	return awsCredentials{}, errors.New("no aws credentials found in the environment, the shared credentials file, the container or the instance metadata")
+}
+
+func (p *awsCredentialsProvider) fromEnv() (awsCredentials, error) {
+	return awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// fromSharedFile reads the profile of AWS_PROFILE, or the default one, in
+// the shared credentials file.
+func (p *awsCredentialsProvider) fromSharedFile() (awsCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return awsCredentials{}, nil
+	}
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("could not open aws credentials file %s: %w", path, err)
+	}
+	defer f.Close()
+	return parseSharedCredentials(f, profile)
+}
+
+func parseSharedCredentials(r io.Reader, profile string) (awsCredentials, error) {
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		i := strings.Index(line, "=")
+		if section != profile || i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch strings.TrimSpace(line[:i]) {
+		case "aws_access_key_id":
+			creds.accessKey = value
+		case "aws_secret_access_key":
+			creds.secretKey = value
+		case "aws_session_token":
+			creds.sessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, fmt.Errorf("could not read aws credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+// roleCredentials is how the container and instance endpoints answer.
+type roleCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (c roleCredentials) credentials() awsCredentials {
+	return awsCredentials{accessKey: c.AccessKeyID, secretKey: c.SecretAccessKey, sessionToken: c.Token, expires: c.Expiration}
+}
+
+// fromContainer gets the credentials of the ecs task role.
+func (p *awsCredentialsProvider) fromContainer() (awsCredentials, error) {
+	url := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		url = "http://169.254.170.2" + relative
+	}
+	if url == "" {
+		return awsCredentials{}, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	var c roleCredentials
+	if err := p.getJSON(req, &c); err != nil {
+		return awsCredentials{}, fmt.Errorf("could not get container credentials: %w", err)
+	}
+	return c.credentials(), nil
+}
+
+// fromInstance gets the credentials of the ec2 instance role through imds
+// v2, no credentials outside of ec2.
+func (p *awsCredentialsProvider) fromInstance() (awsCredentials, error) {
+	req, err := http.NewRequest(http.MethodPut, imdsAddr+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := p.getText(req)
+	if err != nil {
+		// not on ec2.
+		return awsCredentials{}, nil
+	}
+	req, err = http.NewRequest(http.MethodGet, imdsAddr+"/latest/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := p.getText(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("could not get instance role: %w", err)
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	req, err = http.NewRequest(http.MethodGet, imdsAddr+"/latest/meta-data/iam/security-credentials/"+role, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	var c roleCredentials
+	if err := p.getJSON(req, &c); err != nil {
+		return awsCredentials{}, fmt.Errorf("could not get instance credentials: %w", err)
+	}
+	return c.credentials(), nil
+}
+
+func (p *awsCredentialsProvider) getText(req *http.Request) (string, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return string(data), nil
+}
+
+func (p *awsCredentialsProvider) getJSON(req *http.Request, out interface{}) error {
+	text, err := p.getText(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(text), out)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// latencies summarizes the durations of one kind of operation.
+type latencies []time.Duration
+
+func (l latencies) percentile(p float64) time.Duration {
+	if len(l) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(l)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return l[i]
+}
+
+func (l latencies) print(w io.Writer, name string, total time.Duration) {
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	if len(l) == 0 {
+		fmt.Fprintf(w, "%-14s %6d\n", name, 0)
+		return
+	}
+	fmt.Fprintf(w, "%-14s %6d %10.2f %10v %10v %10v %10v %10v\n", name, len(l), float64(len(l))/total.Seconds(),
+		l[0].Round(time.Millisecond), l.percentile(0.5).Round(time.Millisecond), l.percentile(0.9).Round(time.Millisecond),
+		l.percentile(0.99).Round(time.Millisecond), l[len(l)-1].Round(time.Millisecond))
+}
+
+// bench creates count copies of a container of the first target on the
+// first host one after the other, then removes them, and prints the
+// throughput and latency distribution of both operations, to size the churn
+// a host can take.
+func bench(hosts []*host, opts *options) error {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	h := hosts[0]
+	t := opts.targets[0]
+	candidates, err := listCandidates([]*host{h}, t, opts.states)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no container matches %s on host %s", t, h.name)
+	}
+	source := candidates[0]
+	infos, err := h.client.ContainerInspect(context.Background(), source.ID)
+	if err != nil {
+		return fmt.Errorf("could not inspect container id %s: %w", source.ID, err)
+	}
+	sourceConfig := sourceSpec(infos)
+	if err := prepareTargets(h, infos, &sourceConfig, []*host{h}, opts); err != nil {
+		return err
+	}
+	number := nextComposeNumber(candidates)
+
+	copies := []candidate{}
+	creates := latencies{}
+	began := time.Now()
+	var failed error
+	for i := 0; i < opts.count; i++ {
+		spec, err := newCopySpec(sourceConfig, source.ID, t, number+i, opts)
+		if err != nil {
+			return fmt.Errorf("could not prepare copy of container id %s: %w", source.ID, err)
+		}
+		start := time.Now()
+		id, err := createContainer(log, h, spec, opts)
+		if err != nil {
+			failed = err
+			break
+		}
+		creates = append(creates, time.Since(start))
+		copies = append(copies, candidate{Container: types.Container{ID: id, Labels: spec.Config.Labels}, host: h})
+	}
+	createTotal := time.Since(began)
+
+	removes := latencies{}
+	began = time.Now()
+	for _, c := range copies {
+		start := time.Now()
+		if err := removeContainer(log, c, 0, opts); err != nil {
+			failed = err
+			discardContainer(log, h, c.ID, c.Labels[isolatedNetworkLabel])
+			continue
+		}
+		removes = append(removes, time.Since(start))
+	}
+	removeTotal := time.Since(began)
+
+	w := os.Stdout
+	fmt.Fprintf(w, "host %s, image %s\n", h.name, source.Image)
+	fmt.Fprintf(w, "%-14s %6s %10s %10s %10s %10s %10s %10s\n", "operation", "count", "ops/s", "min", "p50", "p90", "p99", "max")
+	creates.print(w, "create+start", createTotal)
+	removes.print(w, "stop+remove", removeTotal)
+	if failed != nil {
+		return fmt.Errorf("benchmark interrupted: %w", failed)
+	}
+	if len(creates) == 0 {
+		return errors.New("no copy created")
+	}
+	return nil
+}
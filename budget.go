@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// percentValue is a flag accepting a ratio written as 5% or 0.05.
+type percentValue struct {
+	value float64
+	set   bool
+}
+
+func (p *percentValue) String() string {
+	if !p.set {
+		return ""
+	}
+	return strconv.FormatFloat(100*p.value, 'f', -1, 64) + "%"
+}
+
+func (p *percentValue) Set(s string) error {
+	divisor := 1.0
+	if strings.HasSuffix(s, "%") {
+		s, divisor = strings.TrimSuffix(s, "%"), 100
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	v /= divisor
+	if v < 0 || v > 1 {
+		return fmt.Errorf("%v is not between 0%% and 100%%", s)
+	}
+	p.value, p.set = v, true
+	return nil
+}
+
+func (p *percentValue) Type() string {
+	return "percent"
+}
+
+// budget tolerates failed create and remove operations as long as they stay
+// below a share of all operations, which is only judged once there are min
+// operations, so that the first failures of a run do not abort it. Without
+// limit, any failure fails the cycle.
+type budget struct {
+	limit    percentValue
+	min      int
+	ops      int
+	failures int
+}
+
+// record counts an operation, it returns the error when it must fail the
+// cycle.
+func (b *budget) record(err error) error {
+	b.ops++
+	if err == nil {
+		return nil
+	}
+	b.failures++
+	bus.publish(busEvent{Kind: eventError, Err: err})
+	if !b.limit.set {
+		return err
+	}
+	logrus.WithError(err).WithField("failures", b.failures).WithField("operations", b.ops).Warn("operation failed within error budget")
+	return nil
+}
+
+func (b *budget) exceeded() bool {
+	return b.limit.set && b.ops > 0 && b.ops >= b.min && float64(b.failures)/float64(b.ops) > b.limit.value
+}
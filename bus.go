@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of the events published on the bus.
+const (
+	eventContainerCreated  = "container_created"
+	eventContainerRemoved  = "container_removed"
+	eventContainerReplaced = "container_replaced"
+	eventCycleFinished     = "cycle_finished"
+	eventError             = "error"
+	eventDrift             = "drift"
+	eventExternal          = "external"
+)
+
+// busEvent is something bubble did, published for the subscribers of the
+// bus.
+type busEvent struct {
+	Kind          string
+	Time          time.Time
+	Cycle         int
+	CycleID       string
+	CorrelationID string
+	Container     string
+	// Host is the docker host, or the orchestrator group, of the container.
+	Host  string
+	Image string
+	// Err is the failure of an error event or of a cycle.
+	Err      error
+	Duration time.Duration
+}
+
+// subscriber consumes the events of the bus. Events are handled
+// synchronously by the goroutine publishing them, handlers must be quick.
+type subscriber interface {
+	handle(e busEvent)
+}
+
+// eventBus delivers the events to every subscriber, so that metrics, the
+// action stream and notifiers do not have to be called inline.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+}
+
+// bus always counts the events in the metrics.
+var bus = &eventBus{subscribers: []subscriber{metricsSubscriber{}}}
+
+func (b *eventBus) subscribe(s subscriber) {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, s)
+	b.mu.Unlock()
+}
+
+func (b *eventBus) publish(e busEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Err = redaction.err(e.Err)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscribers {
+		s.handle(e)
+	}
+}
+
+// metricsSubscriber counts the events in the metrics registry.
+type metricsSubscriber struct{}
+
+func (metricsSubscriber) handle(e busEvent) {
+	switch e.Kind {
+	case eventContainerCreated:
+		registry.inc(metricCreated, label{"host", e.Host})
+	case eventContainerRemoved:
+		registry.inc(metricRemoved, label{"host", e.Host})
+	case eventContainerReplaced:
+		registry.inc(metricReplaced, label{"host", e.Host})
+	case eventCycleFinished:
+		registry.inc(metricCycles)
+		if e.Err != nil {
+			registry.inc(metricCyclesFailed)
+		}
+	case eventError:
+		registry.inc(metricOpsFailed)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// candidateEvents are the container events which change the candidates of
+// the targets, besides the ones of inspectEvents.
+var candidateEvents = []string{"pause", "unpause"}
+
+// candidateCache keeps the candidates of the targets up to date from the
+// container events, instead of listing every container of every host each
+// cycle. The candidates of a target are listed again every reconcile
+// interval, in case events were missed.
+type candidateCache struct {
+	interval time.Duration
+	targets  map[string]*cachedCandidates
+}
+
+type cachedCandidates struct {
+	target target
+	listed time.Time
+	// candidates are the candidates by host and id.
+	candidates map[*host]map[string]candidate
+}
+
+func newCandidateCache(interval time.Duration) *candidateCache {
+	return &candidateCache{interval: interval, targets: map[string]*cachedCandidates{}}
+}
+
+// list returns the candidates of the target, listing them when they are not
+// cached or were listed more than the reconcile interval ago.
+func (c *candidateCache) list(hosts []*host, t target, states []string) ([]candidate, error) {
+	cached, ok := c.targets[t.String()]
+	if !ok || time.Since(cached.listed) >= c.interval {
+		candidates, err := listCandidates(hosts, t, states)
+		if err != nil {
+			return nil, err
+		}
+		cached = &cachedCandidates{target: t, listed: time.Now(), candidates: map[*host]map[string]candidate{}}
+		for _, added := range candidates {
+			cached.put(added)
+		}
+		c.targets[t.String()] = cached
+		return candidates, nil
+	}
+	candidates := []candidate{}
+	for _, h := range hosts {
+		for _, cachedCandidate := range cached.candidates[h] {
+			candidates = append(candidates, cachedCandidate)
+		}
+	}
+	return candidates, nil
+}
+
+func (c *cachedCandidates) put(added candidate) {
+	if c.candidates[added.host] == nil {
+		c.candidates[added.host] = map[string]candidate{}
+	}
+	c.candidates[added.host][added.ID] = added
+}
+
+// update updates the candidates with the container of the event, as the
+// daemon lists it now.
+func (c *candidateCache) update(e containerEvent, states []string) {
+	if len(c.targets) == 0 {
+		return
+	}
+	id := e.message.Actor.ID
+	var container *types.Container
+	if e.message.Action != "destroy" {
+		args := filters.NewArgs(filters.Arg("id", id))
+		containers, err := e.host.client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: args})
+		if err != nil {
+			// the candidates of the host are not known anymore.
+			logrus.WithError(err).WithField("container", id).WithField("host", e.host.name).Warn("could not list container of event")
+			c.invalidate()
+			return
+		}
+		if len(containers) > 0 {
+			container = &containers[0]
+		}
+	}
+	for _, cached := range c.targets {
+		delete(cached.candidates[e.host], id)
+		if container != nil && cached.target.matches(*container) && containsState(states, container.State) {
+			cached.put(candidate{Container: *container, host: e.host})
+		}
+	}
+}
+
+// drop forgets containers bubble removed, without waiting for their events.
+func (c *candidateCache) drop(removed []candidate) {
+	for _, cached := range c.targets {
+		for _, c := range removed {
+			delete(cached.candidates[c.host], c.ID)
+		}
+	}
+}
+
+// invalidate makes the next cycles list the candidates again.
+func (c *candidateCache) invalidate() {
+	c.targets = map[string]*cachedCandidates{}
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// listCandidates lists the candidates of the target, from the candidate
+// cache when it is enabled.
+func (r *runner) listCandidates(t target) ([]candidate, error) {
+	if r.candidates != nil {
+		return r.candidates.list(r.hosts, t, r.opts.states)
+	}
+	return listCandidates(r.hosts, t, r.opts.states)
+}
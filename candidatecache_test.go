@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestCandidateCache(t *testing.T) {
+	h := &host{name: "local"}
+	redis := target{image: "redis"}
+	c := newCandidateCache(time.Minute)
+	cached := &cachedCandidates{target: redis, listed: time.Now(), candidates: map[*host]map[string]candidate{}}
+	for _, id := range []string{"a", "b", "c"} {
+		cached.put(candidate{Container: types.Container{ID: id, Image: "redis", State: stateRunning}, host: h})
+	}
+	c.targets[redis.String()] = cached
+
+	c.update(containerEvent{host: h, message: events.Message{Action: "destroy", Actor: events.Actor{ID: "a"}}}, []string{stateRunning})
+	c.drop([]candidate{{Container: types.Container{ID: "b"}, host: h}})
+	// the candidates are fresh, they are not listed again: the host has no
+	// client.
+	candidates, err := c.list([]*host{h}, redis, []string{stateRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].ID != "c" {
+		t.Fatalf("got candidates %v, want c", candidates)
+	}
+	c.invalidate()
+	if len(c.targets) != 0 {
+		t.Fatal("candidates not invalidated")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// commands are the bubble commands, run being the default one.
+var commands = []string{"run", "validate", "undo", "simulate", "completion", "version", "bench", "scale-up", "selftest", "dashboard", "self-update"}
+
+// flagValues are the values completed for the flags taking one among a
+// fixed set.
+func flagValues() map[string][]string {
+	return map[string][]string{
+		"backend":         {backendDocker, backendNomad, backendECS},
+		"mode":            {modeChurn, modeUp, modeDown},
+		"spread":          {spreadRoundRobin, spreadWeighted},
+		"gpu-policy":      {gpuShare, gpuRoundRobin, gpuStrip},
+		"victim-strategy": {victimsUniform, victimsAge},
+		"state":           {stateRunning, statePaused, stateExited},
+		"output":          {outputText, outputJSONL},
+		"restart":         {"no", "on-failure", "always", "unless-stopped"},
+		"strip":           stripperNames(),
+		"format":          {dashboardGrafana},
+		"wait-condition":  {waitNotRunning, waitRemoved},
+		"log-mode":        {logModeFull, logModeSummary},
+		"external-policy": {externalAdopt, externalIgnore, externalAlert},
+		"paused":          {pausedSkip, pausedUnpause, pausedVictim},
+		"copy-source":     {sourceNewest, sourceOldest, sourceHealthiest, sourceRandom, sourceOriginal},
+	}
+}
+
+// commandFlags returns the flags of the command.
+func commandFlags(command string) []*flag.Flag {
+	_, fs := newFlagSet(command)
+	flags := []*flag.Flag{}
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+func flagNames(flags []*flag.Flag) string {
+	names := []string{}
+	for _, f := range flags {
+		names = append(names, "--"+f.Name)
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// completion writes the completion script of the shell.
+func completion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		fmt.Fprintln(w, "#compdef bubble")
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("unknown shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	values := flagValues()
+	fmt.Fprintln(w, "_bubble() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" command=run`)
+	fmt.Fprintf(w, "\tcase \"${COMP_WORDS[1]}\" in\n\t%s) command=\"${COMP_WORDS[1]}\" ;;\n\tesac\n", strings.Join(commands, "|"))
+	fmt.Fprintln(w, `	case "$prev" in`)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\t--%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", name, strings.Join(values[name], " "))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	if [[ $COMP_CWORD -eq 1 && "$cur" != -* ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return\n", strings.Join(commands, " "))
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	local flags`)
+	fmt.Fprintln(w, `	case "$command" in`)
+	for _, command := range commands {
+		if command == "completion" {
+			fmt.Fprintln(w, "\tcompletion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")); return ;;")
+			continue
+		}
+		fmt.Fprintf(w, "\t%s) flags=\"%s\" ;;\n", command, flagNames(commandFlags(command)))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "$flags" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o default -F _bubble bubble")
+}
+
+func writeFishCompletion(w io.Writer) {
+	values := flagValues()
+	for _, command := range commands {
+		fmt.Fprintf(w, "complete -c bubble -n __fish_use_subcommand -f -a %s\n", command)
+	}
+	fmt.Fprintln(w, "complete -c bubble -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'")
+	common := map[string]bool{}
+	for _, f := range commandFlags("run") {
+		common[f.Name] = true
+		writeFishFlag(w, "not __fish_seen_subcommand_from completion", f, values[f.Name])
+	}
+	for _, command := range commands {
+		if command == "run" || command == "completion" {
+			continue
+		}
+		for _, f := range commandFlags(command) {
+			if !common[f.Name] {
+				writeFishFlag(w, "__fish_seen_subcommand_from "+command, f, values[f.Name])
+			}
+		}
+	}
+}
+
+func writeFishFlag(w io.Writer, condition string, f *flag.Flag, values []string) {
+	line := fmt.Sprintf("complete -c bubble -n '%s' -l %s", condition, f.Name)
+	if f.Shorthand != "" {
+		line += " -s " + f.Shorthand
+	}
+	if len(values) > 0 {
+		line += fmt.Sprintf(" -x -a '%s'", strings.Join(values, " "))
+	} else if f.Value.Type() != "bool" {
+		line += " -r"
+	}
+	line += fmt.Sprintf(" -d %q", strings.SplitN(f.Usage, ",", 2)[0])
+	fmt.Fprintln(w, line)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Labels set by docker compose on the containers of a project.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+	composeNumberLabel  = "com.docker.compose.container-number"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+)
+
+var composeInvalidChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// composeProjectName is the default project name compose gives to a compose
+// file: the name of its directory.
+func composeProjectName(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	name := composeInvalidChars.ReplaceAllString(strings.ToLower(filepath.Base(filepath.Dir(abs))), "")
+	return strings.TrimLeft(name, "_-"), nil
+}
+
+// checkComposeService checks the compose file declares the service, by
+// looking for it among the direct children of the top level services key.
+func checkComposeService(file, service string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("could not open compose file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	inServices, indent := false, -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(stripComment(scanner.Text()), " \t")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		depth := len(line) - len(trimmed)
+		if depth == 0 {
+			inServices = trimmed == "services:"
+			continue
+		}
+		if !inServices {
+			continue
+		}
+		if indent < 0 {
+			indent = depth
+		}
+		if depth == indent && unquote(strings.TrimSuffix(trimmed, ":")) == service {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read compose file %s: %w", file, err)
+	}
+	return fmt.Errorf("service %s not found in compose file %s", service, file)
+}
+
+// checkComposeCandidates fails clearly when a compose service has no
+// container: copies are derived from the existing containers of the
+// service, its config is not read from the compose file.
+func checkComposeCandidates(hosts []*host, targets []target, states []string) error {
+	for _, t := range targets {
+		if t.service == "" {
+			continue
+		}
+		candidates, err := listCandidates(hosts, t, states)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return withExitCode(exitCodeConfig, fmt.Errorf("service %s of compose project %s has no container to copy, start it with docker compose up first", t.service, t.project))
+		}
+	}
+	return nil
+}
+
+// nextComposeNumber returns the container number following the ones of the
+// candidates, like docker compose up --scale does.
+func nextComposeNumber(candidates []candidate) int {
+	next := 1
+	for _, c := range candidates {
+		if n, err := strconv.Atoi(c.Labels[composeNumberLabel]); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return next
+}
+
+func isComposeContainer(container types.Container, project, service string) bool {
+	return container.Labels[composeProjectLabel] == project && container.Labels[composeServiceLabel] == service
+}
+
+// prepareCompose names and labels the copy like the container number of a
+// compose service.
+func prepareCompose(spec *copySpec, t target, number int) {
+	spec.Config.Labels[composeNumberLabel] = strconv.Itoa(number)
+	spec.Config.Labels[composeOneoffLabel] = "False"
+	spec.Name = fmt.Sprintf("%s-%s-%d", t.project, t.service, number)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestPrepareNetworksComposeAlias(t *testing.T) {
+	tests := []struct {
+		service string
+		keep    bool
+		want    []string
+	}{
+		{"web", false, []string{"web", "app-web-1-abc123"}},
+		{"", false, []string{"web-abc123", "app-web-1-abc123"}},
+		{"web", true, []string{"web", "app-web-1"}},
+	}
+	for _, test := range tests {
+		spec := copySpec{NetworkingConfig: &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			"app_default": {Aliases: []string{"web", "app-web-1", "0123456789ab"}},
+		}}}
+		prepareNetworks(&spec, "0123456789abcdef", "abc123", test.service, &options{keepAliases: test.keep})
+		got := spec.NetworkingConfig.EndpointsConfig["app_default"].Aliases
+		if len(got) != len(test.want) || got[0] != test.want[0] || got[1] != test.want[1] {
+			t.Errorf("service %q, keep %v: aliases %v, want %v", test.service, test.keep, got, test.want)
+		}
+	}
+}
+
+func TestCheckComposeCandidates(t *testing.T) {
+	web := types.Container{ID: "web1", State: stateRunning, Labels: map[string]string{composeProjectLabel: "app", composeServiceLabel: "web"}}
+	tests := []struct {
+		containers []types.Container
+		ok         bool
+	}{
+		{[]types.Container{web}, true},
+		{nil, false},
+	}
+	for _, test := range tests {
+		m := &mockClient{ListFunc: func(options types.ContainerListOptions) ([]types.Container, error) {
+			return test.containers, nil
+		}}
+		targets := []target{{image: "redis"}, {project: "app", service: "web"}}
+		err := checkComposeCandidates([]*host{mockHost(m)}, targets, []string{stateRunning})
+		if (err == nil) != test.ok {
+			t.Errorf("%v containers: checkComposeCandidates = %v, want ok %v", len(test.containers), err, test.ok)
+		}
+		if err != nil && exitCode(err) != exitCodeConfig {
+			t.Errorf("exit code %v, want %v", exitCode(err), exitCodeConfig)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// configEntry is one top level key of a config file, with one value for
+// scalars and several for lists.
+type configEntry struct {
+	key    string
+	values []string
+	line   int
+}
+
+// readConfig reads the flat subset of YAML bubble understands: top level
+// "key: value" pairs and lists of scalars written as "- value" items.
+// Keys are the long names of the command line flags.
+func readConfig(path string) ([]configEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open config file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	entries := []configEntry{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(stripComment(scanner.Text()), " \t")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if len(entries) == 0 {
+				return nil, fmt.Errorf("%s:%d: list item outside of a key", path, n)
+			}
+			last := &entries[len(entries)-1]
+			last.values = append(last.values, unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))))
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("%s:%d: nested keys are not supported", path, n)
+		}
+		i := strings.Index(trimmed, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, n)
+		}
+		entry := configEntry{key: strings.TrimSpace(trimmed[:i]), line: n}
+		if value := strings.TrimSpace(trimmed[i+1:]); value != "" {
+			entry.values = []string{unquote(value)}
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// loadConfig sets the flags of fs from the config file, skipping the ones
+// already given on the command line.
+func loadConfig(path string, fs *flag.FlagSet) error {
+	entries, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		f := fs.Lookup(entry.key)
+		if f == nil || entry.key == "config" {
+			return fmt.Errorf("%s:%d: unknown option %q", path, entry.line, entry.key)
+		}
+		if f.Changed {
+			continue
+		}
+		for _, value := range entry.values {
+			if err := fs.Set(entry.key, value); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %w", path, entry.line, value, entry.key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
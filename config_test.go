@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bubble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "bubble.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []configEntry
+		err     bool
+	}{
+		{"scalar", "freq: 10s\n", []configEntry{{key: "freq", values: []string{"10s"}, line: 1}}, false},
+		{"list", "image:\n  - redis\n  - 'nginx:1'\n", []configEntry{{key: "image", values: []string{"redis", "nginx:1"}, line: 1}}, false},
+		{"comments and blank lines", "# bubble\n\nfreq: 10s # every 10s\n", []configEntry{{key: "freq", values: []string{"10s"}, line: 3}}, false},
+		{"hash in quotes", "probe-url: \"http://x/#a\"\n", []configEntry{{key: "probe-url", values: []string{"http://x/#a"}, line: 1}}, false},
+		{"hash in value", "label: a#b\n", []configEntry{{key: "label", values: []string{"a#b"}, line: 1}}, false},
+		{"empty value", "image:\n", []configEntry{{key: "image", line: 1}}, false},
+		{"item outside key", "- redis\n", nil, true},
+		{"nested key", "a:\n  b: c\n", nil, true},
+		{"no colon", "freq\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readConfig(writeConfig(t, tt.content))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	freq := fs.String("freq", "", "")
+	images := fs.StringSlice("image", nil, "")
+	if err := fs.Parse([]string{"--freq", "1s"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(writeConfig(t, "freq: 10s\nimage:\n  - redis\n  - nginx\n"), fs); err != nil {
+		t.Fatal(err)
+	}
+	if *freq != "1s" {
+		t.Errorf("command line freq overridden by config: %s", *freq)
+	}
+	if want := []string{"redis", "nginx"}; !reflect.DeepEqual(*images, want) {
+		t.Errorf("got images %v, want %v", *images, want)
+	}
+	if err := loadConfig(writeConfig(t, "unknown: 1\n"), fs); err == nil {
+		t.Error("expected an error for an unknown option")
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// copyContainer creates and starts one copy of the source container of the
+// plan on each of its target hosts, and returns the copies it created. With a
+// standby pool, ready copies are started instead and the pool is replenished.
+// With frozen sources, the frozen source of the target is copied instead.
+func copyContainer(log *logrus.Entry, p plan, opts *options, budget *budget, pool *standbyPool, frozen *frozenSources) ([]created, error) {
+	copies := []created{}
+	if len(p.targets) == 0 {
+		return copies, nil
+	}
+	source, infos, err := frozen.source(p)
+	if err != nil {
+		return copies, err
+	}
+	sourceConfig := sourceSpec(infos)
+	if err := prepareTargets(source.host, infos, &sourceConfig, p.targets, opts); err != nil {
+		return copies, err
+	}
+	for i, target := range p.targets {
+		spec, err := newCopySpec(sourceConfig, source.ID, p.target, p.number+i, opts)
+		if err != nil {
+			return copies, fmt.Errorf("could not prepare copy of container id %s: %w", source.ID, err)
+		}
+		if p.image != "" {
+			if target == source.host {
+				spec.Config.Image = p.image
+			} else {
+				log.WithField("host", target.name).Warn("snapshot only exists on the source host, copy created from the source image")
+			}
+		}
+		if target.osType() == osWindows {
+			prepareWindows(&spec)
+		}
+		// the diff is the one of the create request, the standby copies
+		// being created from specs prepared alike.
+		var id string
+		if s, ok := pool.take(log, p.target, target, spec.Config.Image); ok {
+			if opts.diff {
+				logDiff(source.ID, sourceConfig, s.spec)
+			}
+			id, err = startStandby(log, target, s, opts)
+			spec = s.spec
+		} else {
+			var ports []int
+			if ports, err = prepareCreate(log, target, &spec, opts); err == nil {
+				if opts.diff {
+					logDiff(source.ID, sourceConfig, spec)
+				}
+				id, err = createPrepared(log, target, spec, ports, opts)
+			}
+		}
+		if id != "" {
+			copies = append(copies, created{host: target, id: id, isolated: spec.Config.Labels[isolatedNetworkLabel]})
+		}
+		if err := budget.record(err); err != nil {
+			return copies, err
+		}
+		if opts.atomic && err != nil {
+			return copies, err
+		}
+	}
+	if pool != nil {
+		pool.replenish(log, p.target, p.targets, sourceConfig, source.ID, p.number+len(p.targets), opts)
+	}
+	return copies, nil
+}
+
+// prepareTargets readies the image of the source on every target host before
+// copies are created there. With a verify key, the signature of the source
+// image is verified and the copies are pinned to its digest, pulled instead
+// of the tag which may have been pushed again since.
+func prepareTargets(source *host, infos types.ContainerJSON, sourceConfig *copySpec, targets []*host, opts *options) error {
+	if opts.verifyKey != "" {
+		digest, err := imageDigest(source, infos.Image, sourceConfig.Config.Image)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(source, digest, digest, opts); err != nil {
+			return err
+		}
+		sourceConfig.Config.Image = digest
+	}
+	checked := map[*host]bool{}
+	for _, target := range targets {
+		if checked[target] {
+			continue
+		}
+		if opts.pull {
+			if err := pullImage(target, sourceConfig.Config.Image, "", opts); err != nil {
+				return err
+			}
+		}
+		if err := checkPlatform(target, sourceConfig.Config.Image, opts); err != nil {
+			return err
+		}
+		checked[target] = true
+	}
+	return nil
+}
+
+// createContainer creates and starts a container from spec on the target,
+// then waits for it to be ready, and returns its id. The container is
+// removed when any step after its creation fails.
+func createContainer(log *logrus.Entry, target *host, spec copySpec, opts *options) (string, error) {
+	ports, err := prepareCreate(log, target, &spec, opts)
+	if err != nil {
+		return "", err
+	}
+	return createPrepared(log, target, spec, ports, opts)
+}
+
+// createPrepared is createContainer for a spec already prepared for the
+// target, with its allocated host ports.
+func createPrepared(log *logrus.Entry, target *host, spec copySpec, ports []int, opts *options) (string, error) {
+	id, err := createStopped(log, target, &spec, ports, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := startContainer(log, target, id, spec, opts); err != nil {
+		discardContainer(log, target, id, spec.Config.Labels[isolatedNetworkLabel])
+		return "", err
+	}
+	return id, nil
+}
+
+// prepareCreate readies the spec for the daemon of the target, so that it is
+// the payload of the create request: it stamps its correlation id,
+// downgrades what a rootless daemon can not honor and gives managed copies
+// host ports of their own, the ones of their source being taken. It returns
+// the allocated host ports, assigned on creation.
+func prepareCreate(log *logrus.Entry, target *host, spec *copySpec, opts *options) ([]int, error) {
+	if spec.Config.Labels == nil {
+		spec.Config.Labels = map[string]string{}
+	}
+	spec.Config.Labels[correlationLabel] = newID()
+	if target.isRootless() {
+		prepareRootless(log, target, spec)
+	}
+	if spec.Config.Labels[managedLabel] != "true" {
+		return nil, nil
+	}
+	return hostPorts.prepare(target, spec, opts.hostPortRange)
+}
+
+// createStopped creates a container from the prepared spec on the target
+// without starting it, and returns its id. The allocated host ports are
+// assigned to it, or freed when it could not be created.
+func createStopped(log *logrus.Entry, target *host, spec *copySpec, ports []int, opts *options) (string, error) {
+	client := target.client
+	if opts.verifyKey != "" {
+		if err := verifyImage(target, spec.Config.Image, opts); err != nil {
+			hostPorts.cancel(target, ports)
+			return "", err
+		}
+	}
+	isolated := spec.Config.Labels[isolatedNetworkLabel]
+	if isolated != "" {
+		if err := createIsolatedNetwork(target, isolated); err != nil {
+			hostPorts.cancel(target, ports)
+			return "", err
+		}
+	}
+	began := time.Now()
+	createdBody, err := client.ContainerCreate(
+		context.Background(),
+		spec.Config,
+		spec.HostConfig,
+		spec.NetworkingConfig,
+		nil,
+		spec.Name,
+	)
+	timeStep(stepCreate, began)
+	if err != nil {
+		hostPorts.cancel(target, ports)
+		if isolated != "" {
+			if err := removeIsolatedNetwork(target, isolated); err != nil {
+				log.WithError(err).Error("could not discard network")
+			}
+		}
+		return "", fmt.Errorf("could not create container on host %s: %w", target.name, err)
+	}
+	hostPorts.assign(target, ports, createdBody.ID)
+	for _, warning := range createdBody.Warnings {
+		log.Warn(warning)
+	}
+	log.WithField("container", createdBody.ID).WithField("host", target.name).WithField("correlation_id", spec.Config.Labels[correlationLabel]).Info("create container")
+	return createdBody.ID, nil
+}
+
+// startContainer connects the created container to its extra networks,
+// starts it and waits for it to be ready.
+func startContainer(log *logrus.Entry, target *host, id string, spec copySpec, opts *options) error {
+	client := target.client
+	correlation := spec.Config.Labels[correlationLabel]
+	logger := log.WithField("container", id).WithField("host", target.name).WithField("correlation_id", correlation)
+	for name, endpoint := range spec.ExtraEndpoints {
+		if err := client.NetworkConnect(context.Background(), name, id, endpoint); err != nil {
+			return fmt.Errorf("could not connect container id %s to network %s: %w", id, name, err)
+		}
+		logger.WithField("network", name).Info("connect container")
+	}
+	began := time.Now()
+	err := client.ContainerStart(context.Background(), id, types.ContainerStartOptions{})
+	timeStep(stepStart, began)
+	if err != nil {
+		return fmt.Errorf("could not start container id %s: %w", id, err)
+	}
+	logger.Info("start container")
+	if err := waitReady(log, target, id, opts); err != nil {
+		return err
+	}
+	bus.publish(busEvent{Kind: eventContainerCreated, Container: id, Host: target.name, Image: spec.Config.Image, CycleID: cycleIDOf(log), CorrelationID: correlation})
+	return nil
+}
+
+// discardContainer force removes a copy which failed to start or to become
+// ready, so that dead copies do not accumulate on the host.
+func discardContainer(log *logrus.Entry, target *host, id, isolated string) {
+	logger := log.WithField("container", id).WithField("host", target.name)
+	if err := target.client.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true}); err != nil {
+		logger.WithError(err).Error("could not discard container")
+		return
+	}
+	logger.Info("discard container")
+	hostPorts.release(target, id)
+	if isolated != "" {
+		if err := removeIsolatedNetwork(target, isolated); err != nil {
+			logger.WithError(err).Error("could not discard network")
+		}
+	}
+}
+
+// deleteContainer removes the victims and returns the ones it removed.
+func deleteContainer(log *logrus.Entry, victims []candidate, cycle int, opts *options, budget *budget) ([]candidate, error) {
+	removed := []candidate{}
+	for _, container := range victims {
+		if locked(opts) {
+			log.WithField("lock", opts.lock).Warn("lock held, deletions paused")
+			return removed, nil
+		}
+		err := removeContainer(log, container, cycle, opts)
+		if err == nil {
+			removed = append(removed, container)
+		}
+		if err := budget.record(err); err != nil {
+			return removed, err
+		}
+		if opts.atomic && err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// removeContainer stops the container, waits for it and removes it, waiting
+// for its removal too with the removed wait condition.
+func removeContainer(log *logrus.Entry, container candidate, cycle int, opts *options) error {
+	client := container.host.client
+	correlation := correlationID(container.Labels)
+	logger := log.WithField("container", container.ID).WithField("host", container.host.name).WithField("correlation_id", correlation)
+	if opts.snapshotBeforeDelete {
+		reference, err := snapshotVictim(container, cycle)
+		if err != nil {
+			return err
+		}
+		logger.WithField("image", reference).Info("snapshot container")
+	}
+	began := time.Now()
+	err := stopVictim(container, opts)
+	timeStep(stepStop, began)
+	if err != nil {
+		return fmt.Errorf("could not stop container id: %s: %w", container.ID, err)
+	}
+	logger.Info("stop container")
+	if err := startWait(log, container.host, container.ID, waitNotRunning, opts).wait(); err != nil {
+		return err
+	}
+	began = time.Now()
+	infos, err := client.ContainerInspect(context.Background(), container.ID)
+	timeStep(stepInspect, began)
+	if err != nil {
+		logger.WithError(err).Warn("could not inspect stopped container")
+	} else {
+		exits.record(container.host, container.ID, infos.State, exitStopped)
+	}
+	if opts.archiveLogs != "" {
+		path, err := archiveLogs(container, cycle, opts.archiveLogs)
+		if err != nil {
+			return err
+		}
+		logger.WithField("path", path).Info("archive logs")
+	}
+	var removal *waiter
+	if opts.waitCondition == waitRemoved {
+		removal = startWait(log, container.host, container.ID, waitRemoved, opts)
+	}
+	began = time.Now()
+	err = client.ContainerRemove(context.Background(), container.ID, types.ContainerRemoveOptions{})
+	timeStep(stepRemove, began)
+	if err != nil {
+		if removal != nil {
+			removal.cancel()
+		}
+		return fmt.Errorf("could not remove container id  %s: %w", container.ID, err)
+	}
+	if removal != nil {
+		if err := removal.wait(); err != nil {
+			return err
+		}
+	}
+	logger.Info("remove container")
+	hostPorts.release(container.host, container.ID)
+	bus.publish(busEvent{Kind: eventContainerRemoved, Cycle: cycle, Container: container.ID, Host: container.host.name, Image: container.Image, CycleID: cycleIDOf(log), CorrelationID: correlation})
+	if isolated := container.Labels[isolatedNetworkLabel]; isolated != "" {
+		return removeIsolatedNetwork(container.host, isolated)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateContainer(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	for _, tc := range []struct {
+		name  string
+		mock  *mockClient
+		ok    bool
+		calls string
+	}{
+		{"created", &mockClient{}, true, "create copy, start copy"},
+		{"create failed", &mockClient{CreateFunc: func(*ac.Config, *ac.HostConfig, string) (ac.ContainerCreateCreatedBody, error) {
+			return ac.ContainerCreateCreatedBody{}, errors.New("no space left")
+		}}, false, "create copy"},
+		{"start failed", &mockClient{StartFunc: func(string) error {
+			return errors.New("port is already allocated")
+		}}, false, "create copy, start copy, remove copy"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := copySpec{Name: "copy", Config: &ac.Config{Image: "redis", Labels: map[string]string{}}}
+			id, err := createContainer(log, mockHost(tc.mock), spec, &options{})
+			if (err == nil) != tc.ok || (id == "copy") != tc.ok {
+				t.Errorf("got %q, %v", id, err)
+			}
+			if got := tc.mock.Calls(); got != tc.calls {
+				t.Errorf("got calls %q, want %q", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestPrepareCreate(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	var sent copySpec
+	m := &mockClient{CreateFunc: func(config *ac.Config, hostConfig *ac.HostConfig, name string) (ac.ContainerCreateCreatedBody, error) {
+		sent = copySpec{Name: name, Config: config, HostConfig: hostConfig}
+		return ac.ContainerCreateCreatedBody{ID: name}, nil
+	}}
+	h := mockHost(m)
+	for _, tc := range []struct {
+		name    string
+		managed bool
+		port    string
+	}{
+		{"managed copy", true, "30000"},
+		{"other container", false, "8080"},
+	} {
+		spec := copySpec{
+			Name:       "copy",
+			Config:     &ac.Config{Image: "redis", Labels: map[string]string{managedLabel: strconv.FormatBool(tc.managed)}},
+			HostConfig: &ac.HostConfig{PortBindings: nat.PortMap{"80/tcp": {{HostPort: "8080"}}}},
+		}
+		opts := &options{hostPortRange: portRange{30000, 30001}}
+		ports, err := prepareCreate(log, h, &spec, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		// the prepared spec, the one --diff logs, is the payload sent.
+		prepared, err := spec.clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := createStopped(log, h, &spec, ports, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		hostPorts.release(h, id)
+		if !reflect.DeepEqual(sent.Config, prepared.Config) || !reflect.DeepEqual(sent.HostConfig, prepared.HostConfig) {
+			t.Errorf("%s: sent %+v, %+v, prepared %+v, %+v", tc.name, sent.Config, sent.HostConfig, prepared.Config, prepared.HostConfig)
+		}
+		if prepared.Config.Labels[correlationLabel] == "" {
+			t.Errorf("%s: no correlation id prepared", tc.name)
+		}
+		if got := prepared.HostConfig.PortBindings["80/tcp"][0].HostPort; got != tc.port {
+			t.Errorf("%s: host port %q, want %q", tc.name, got, tc.port)
+		}
+	}
+}
+
+func TestRemoveContainer(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	opts := &options{waitTimeout: time.Second, waitCondition: waitNotRunning}
+	for _, tc := range []struct {
+		name  string
+		mock  *mockClient
+		opts  options
+		ok    bool
+		calls string
+	}{
+		{"removed", &mockClient{}, *opts, true, "stop victim, wait victim, inspect victim, remove victim"},
+		{"removal waited", &mockClient{}, options{waitTimeout: time.Second, waitCondition: waitRemoved}, true, "stop victim, wait victim, inspect victim, wait victim, remove victim"},
+		{"stop failed", &mockClient{StopFunc: func(string) error {
+			return errors.New("cannot stop")
+		}}, *opts, false, "stop victim"},
+		{"gone while waiting", &mockClient{WaitFunc: func(string, ac.WaitCondition) (ac.ContainerWaitOKBody, error) {
+			return ac.ContainerWaitOKBody{}, errdefs.NotFound(errors.New("no such container"))
+		}}, *opts, true, "stop victim, wait victim, inspect victim, remove victim"},
+		{"remove failed", &mockClient{RemoveFunc: func(string, types.ContainerRemoveOptions) error {
+			return errors.New("removal in progress")
+		}}, *opts, false, "stop victim, wait victim, inspect victim, remove victim"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			victim := candidate{Container: types.Container{ID: "victim", Image: "redis"}, host: mockHost(tc.mock)}
+			err := removeContainer(log, victim, 1, &tc.opts)
+			if (err == nil) != tc.ok {
+				t.Errorf("got error %v", err)
+			}
+			if got := tc.mock.Calls(); got != tc.calls {
+				t.Errorf("got calls %q, want %q", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestListCandidates(t *testing.T) {
+	redis := types.Container{ID: "redis", Image: "redis", State: stateRunning}
+	copied := types.Container{ID: "copy", Image: "redis:snapshot", State: stateRunning, Labels: map[string]string{targetLabel: "redis"}}
+	built := types.Container{ID: "built", Image: "redis-with-modules", State: stateRunning}
+	m := &mockClient{ListFunc: func(options types.ContainerListOptions) ([]types.Container, error) {
+		if options.Filters.Contains("label") {
+			return []types.Container{copied}, nil
+		}
+		// ancestor filters match the images built from the target too.
+		return []types.Container{redis, copied, built}, nil
+	}}
+	candidates, err := listCandidates([]*host{mockHost(m)}, target{image: "redis"}, []string{stateRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) != 2 || ids[0] != "copy" || ids[1] != "redis" {
+		t.Errorf("got candidates %v, want copy and redis", ids)
+	}
+	m.ListFunc = func(types.ContainerListOptions) ([]types.Container, error) {
+		return nil, errors.New("daemon unreachable")
+	}
+	if _, err := listCandidates([]*host{mockHost(m)}, target{image: "redis"}, []string{stateRunning}); err == nil {
+		t.Error("listing failure ignored")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// coordinator divides the work of a cycle among the bubbles sharing a redis
+// prefix, each churning its own hosts: every bubble publishes the number of
+// candidates of the target it churns, the creations and deletions of the
+// cycle, plus the ones converging to the global count, are divided evenly
+// among them.
+type coordinator struct {
+	redis  *redisClient
+	prefix string
+	id     string
+	ttl    time.Duration
+	global int
+}
+
+func newCoordinator(opts *options) *coordinator {
+	hostname, _ := os.Hostname()
+	return &coordinator{
+		redis:  newRedisClient(opts.redisAddr, opts.redisPassword),
+		prefix: opts.coordinateKey,
+		id:     hostname + ":" + strconv.Itoa(os.Getpid()),
+		ttl:    3 * opts.freq,
+		global: opts.globalCount,
+	}
+}
+
+func (c *coordinator) key(t target, id string) string {
+	return c.prefix + ":" + t.String() + ":" + id
+}
+
+// peers publishes the local count of the target and returns the counts of
+// every live bubble by id, including this one.
+func (c *coordinator) peers(t target, count int) (map[string]int, error) {
+	ttl := strconv.FormatInt(c.ttl.Milliseconds(), 10)
+	if _, err := c.redis.do("SET", c.key(t, c.id), strconv.Itoa(count), "PX", ttl); err != nil {
+		return nil, fmt.Errorf("could not publish count: %w", err)
+	}
+	pattern := c.key(t, "*")
+	counts := map[string]int{}
+	cursor := "0"
+	for {
+		reply, err := c.redis.do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, fmt.Errorf("could not list peers: %w", err)
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) != 2 {
+			return nil, fmt.Errorf("unexpected scan reply %v", reply)
+		}
+		cursor, _ = values[0].(string)
+		keys, _ := values[1].([]interface{})
+		for _, k := range keys {
+			key, _ := k.(string)
+			reply, err := c.redis.do("GET", key)
+			if err == errRedisNil {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not get peer count: %w", err)
+			}
+			s, _ := reply.(string)
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				continue
+			}
+			counts[strings.TrimPrefix(key, c.key(t, ""))] = n
+		}
+		if cursor == "0" {
+			return counts, nil
+		}
+	}
+}
+
+// share returns the part of total assigned to the peer of the index among
+// n, the remainder going to the first peers.
+func share(total, index, n int) int {
+	part := total / n
+	if index < total%n {
+		part++
+	}
+	return part
+}
+
+// divide returns the creations and deletions of the cycle this bubble has
+// to perform, given its count of the target.
+func (c *coordinator) divide(t target, count int, ratio RatioValue) (RatioValue, error) {
+	counts, err := c.peers(t, count)
+	if err != nil {
+		return RatioValue{}, err
+	}
+	ids := make([]string, 0, len(counts))
+	total := 0
+	for id, n := range counts {
+		ids = append(ids, id)
+		total += n
+	}
+	sort.Strings(ids)
+	index := sort.SearchStrings(ids, c.id)
+	// percentages are of the containers of every bubble.
+	ratio = ratio.resolve(total)
+	up, down := int(ratio.Up), int(ratio.Down)
+	if c.global > 0 {
+		if diff := c.global - total; diff > 0 {
+			up += diff
+		} else {
+			down -= diff
+		}
+	}
+	mine := RatioValue{Up: uint64(share(up, index, len(ids))), Down: uint64(share(down, index, len(ids)))}
+	if int(mine.Down) > count {
+		mine.Down = uint64(count)
+	}
+	logrus.WithField("peers", len(ids)).WithField("global", total).WithField("creations", mine.Up).WithField("deletions", mine.Down).Debug("cycle work divided")
+	return mine, nil
+}
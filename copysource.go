@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const (
+	sourceNewest     = "newest"
+	sourceOldest     = "oldest"
+	sourceHealthiest = "healthiest"
+	sourceRandom     = "random"
+	sourceOriginal   = "original"
+)
+
+func checkCopySource(policy string) error {
+	switch policy {
+	case sourceNewest, sourceOldest, sourceHealthiest, sourceRandom, sourceOriginal:
+		return nil
+	}
+	return fmt.Errorf("unknown copy source %q, expected %s, %s, %s, %s or %s", policy, sourceNewest, sourceOldest, sourceHealthiest, sourceRandom, sourceOriginal)
+}
+
+// pickSource picks the candidate copies are derived from with the copy
+// source policy: the most or the least recently created one, a random one
+// among the healthiest, a random one, or a random one among the containers
+// not created by bubble, the oldest candidate once none is left. Candidates
+// must not be empty.
+func pickSource(candidates []candidate, policy string) candidate {
+	switch policy {
+	case sourceNewest, sourceOldest:
+		picked := candidates[0]
+		for _, c := range candidates[1:] {
+			if policy == sourceNewest && c.Created > picked.Created || policy == sourceOldest && c.Created < picked.Created {
+				picked = c
+			}
+		}
+		return picked
+	case sourceHealthiest:
+		best, healthiest := -1, []candidate{}
+		for _, c := range candidates {
+			switch rank := healthRank(c); {
+			case rank > best:
+				best, healthiest = rank, []candidate{c}
+			case rank == best:
+				healthiest = append(healthiest, c)
+			}
+		}
+		return healthiest[rand.Intn(len(healthiest))]
+	case sourceOriginal:
+		originals := []candidate{}
+		for _, c := range candidates {
+			if c.Labels[managedLabel] != "true" {
+				originals = append(originals, c)
+			}
+		}
+		if len(originals) == 0 {
+			return pickSource(candidates, sourceOldest)
+		}
+		return originals[rand.Intn(len(originals))]
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// healthRank ranks the candidate by its state and health status: healthy
+// running containers first, then running ones without health check or still
+// starting, unhealthy ones and the ones not running last.
+func healthRank(c candidate) int {
+	if c.State != stateRunning {
+		return 0
+	}
+	switch {
+	case strings.Contains(c.Status, "(healthy)"):
+		return 3
+	case strings.Contains(c.Status, "(unhealthy)"):
+		return 1
+	}
+	return 2
+}
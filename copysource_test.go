@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestPickSource(t *testing.T) {
+	newCandidate := func(id, state, status string, created int64, managed bool) candidate {
+		labels := map[string]string{}
+		if managed {
+			labels[managedLabel] = "true"
+		}
+		return candidate{Container: types.Container{ID: id, State: state, Status: status, Created: created, Labels: labels}}
+	}
+	fleet := []candidate{
+		newCandidate("original", stateRunning, "Up 3 days (unhealthy)", 100, false),
+		newCandidate("copy1", stateRunning, "Up 2 hours (healthy)", 200, true),
+		newCandidate("copy2", stateRunning, "Up 1 hour", 300, true),
+		newCandidate("copy3", stateExited, "Exited (0) 5 minutes ago", 400, true),
+	}
+	copies := fleet[1:]
+	tests := []struct {
+		candidates []candidate
+		policy     string
+		want       string
+	}{
+		{fleet, sourceNewest, "copy3"},
+		{fleet, sourceOldest, "original"},
+		{fleet, sourceHealthiest, "copy1"},
+		{fleet[2:], sourceHealthiest, "copy2"},
+		{fleet, sourceOriginal, "original"},
+		{copies, sourceOriginal, "copy1"},
+		{fleet[3:], sourceRandom, "copy3"},
+	}
+	for _, test := range tests {
+		ids := []string{}
+		for _, c := range test.candidates {
+			ids = append(ids, c.ID)
+		}
+		for i := 0; i < 10; i++ {
+			if got := pickSource(test.candidates, test.policy); got.ID != test.want {
+				t.Errorf("pickSource(%s, %s) = %s, want %s", strings.Join(ids, ","), test.policy, got.ID, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// daemonEnv tells a process which stage of the daemonization it is: 1 for
+// the session leader, 2 for the daemon itself.
+const daemonEnv = "BUBBLE_DAEMON"
+
+// daemonize detaches bubble from its terminal like a double fork: the
+// process starts a session leader in a new session, which starts the daemon
+// and exits, so that the daemon can never acquire a terminal again. It
+// returns true in the processes which must exit, false in the daemon.
+func daemonize(opts *options) (bool, error) {
+	stage := os.Getenv(daemonEnv)
+	if stage == "2" {
+		os.Unsetenv(daemonEnv)
+		return false, nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("could not find executable: %w", err)
+	}
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return false, err
+	}
+	defer null.Close()
+	output := null
+	if opts.logFile != "" {
+		if output, err = os.OpenFile(opts.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return false, fmt.Errorf("could not open log file: %w", err)
+		}
+		defer output.Close()
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, output, output
+	if stage == "" {
+		cmd.Env = append(os.Environ(), daemonEnv+"=1")
+		cmd.SysProcAttr = detached()
+	} else {
+		cmd.Env = append(os.Environ(), daemonEnv+"=2")
+	}
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("could not start daemon: %w", err)
+	}
+	if stage == "" {
+		// wait for the session leader so that the daemon is started when
+		// the command returns.
+		if err := cmd.Wait(); err != nil {
+			return false, fmt.Errorf("could not start daemon: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// writePidFile writes the pid of bubble to the file, refusing to when the
+// process of an existing pid file is still running.
+func writePidFile(path string) error {
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && running(pid) {
+			return fmt.Errorf("bubble already running with pid %d according to %s", pid, path)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read pid file: %w", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("could not write pid file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const dashboardGrafana = "grafana"
+
+func checkDashboardFormat(format string) error {
+	if format != dashboardGrafana {
+		return fmt.Errorf("unknown dashboard format %q, expected %s", format, dashboardGrafana)
+	}
+	return nil
+}
+
+// grafanaDashboard is the part of the grafana dashboard model bubble fills.
+type grafanaDashboard struct {
+	UID           string             `json:"uid"`
+	Title         string             `json:"title"`
+	Tags          []string           `json:"tags"`
+	SchemaVersion int                `json:"schemaVersion"`
+	Refresh       string             `json:"refresh"`
+	Time          grafanaTime        `json:"time"`
+	Templating    grafanaTemplating  `json:"templating"`
+	Panels        []grafanaPanel     `json:"panels"`
+	Annotations   grafanaAnnotations `json:"annotations"`
+}
+
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaAnnotations struct {
+	List []interface{} `json:"list"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Type        string             `json:"type"`
+	Datasource  string             `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Targets     []grafanaTarget    `json:"targets"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults grafanaDefaults `json:"defaults"`
+}
+
+type grafanaDefaults struct {
+	Unit string `json:"unit"`
+}
+
+// writeDashboard writes a grafana dashboard with a panel per metric of the
+// registry, so that it always matches the metrics bubble exposes: the rate
+// of the counters, the value of the gauges and quantiles of the histograms,
+// by label.
+func writeDashboard(w io.Writer, format string) error {
+	if err := checkDashboardFormat(format); err != nil {
+		return err
+	}
+	d := grafanaDashboard{
+		UID:           "bubble",
+		Title:         "bubble",
+		Tags:          []string{"bubble"},
+		SchemaVersion: 27,
+		Refresh:       "30s",
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+		Annotations: grafanaAnnotations{List: []interface{}{}},
+	}
+	for i, desc := range metricDescs {
+		d.Panels = append(d.Panels, grafanaPanel{
+			ID:          i + 1,
+			Title:       desc.name,
+			Description: desc.help,
+			Type:        "timeseries",
+			Datasource:  "${datasource}",
+			GridPos:     grafanaGridPos{H: 8, W: 12, X: i % 2 * 12, Y: i / 2 * 8},
+			Targets:     panelTargets(desc),
+			FieldConfig: grafanaFieldConfig{Defaults: grafanaDefaults{Unit: panelUnit(desc)}},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return fmt.Errorf("could not write dashboard: %w", err)
+	}
+	return nil
+}
+
+func panelTargets(desc metricDesc) []grafanaTarget {
+	legend := []string{}
+	for _, l := range desc.labels {
+		legend = append(legend, "{{"+l+"}}")
+	}
+	switch desc.kind {
+	case counterKind:
+		return []grafanaTarget{{RefID: "A", Expr: sumBy(desc.labels, fmt.Sprintf("rate(%s[$__rate_interval])", desc.name)), LegendFormat: legendOf(legend, desc.name)}}
+	case histogramKind:
+		targets := []grafanaTarget{}
+		for i, q := range []struct{ quantile, name string }{{"0.5", "p50"}, {"0.99", "p99"}} {
+			buckets := sumBy(append([]string{"le"}, desc.labels...), fmt.Sprintf("rate(%s_bucket[$__rate_interval])", desc.name))
+			targets = append(targets, grafanaTarget{
+				RefID:        string(rune('A' + i)),
+				Expr:         fmt.Sprintf("histogram_quantile(%s, %s)", q.quantile, buckets),
+				LegendFormat: strings.Join(append(legend, q.name), " "),
+			})
+		}
+		return targets
+	}
+	return []grafanaTarget{{RefID: "A", Expr: sumBy(desc.labels, desc.name), LegendFormat: legendOf(legend, desc.name)}}
+}
+
+// sumBy sums the series of the expression of every bubble by the labels.
+func sumBy(labels []string, expr string) string {
+	if len(labels) == 0 {
+		return "sum(" + expr + ")"
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(labels, ", "), expr)
+}
+
+func legendOf(parts []string, name string) string {
+	if len(parts) == 0 {
+		return name
+	}
+	return strings.Join(parts, " ")
+}
+
+func panelUnit(desc metricDesc) string {
+	switch desc.kind {
+	case histogramKind:
+		return "s"
+	case counterKind:
+		return "ops"
+	}
+	return "short"
+}
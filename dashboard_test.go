@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteDashboard(t *testing.T) {
+	if err := writeDashboard(&bytes.Buffer{}, "kibana"); err == nil {
+		t.Error("unknown format accepted")
+	}
+	var out bytes.Buffer
+	if err := writeDashboard(&out, dashboardGrafana); err != nil {
+		t.Fatal(err)
+	}
+	var d grafanaDashboard
+	if err := json.Unmarshal(out.Bytes(), &d); err != nil {
+		t.Fatalf("invalid dashboard json: %v", err)
+	}
+	if len(d.Panels) != len(metricDescs) {
+		t.Fatalf("got %v panels for %v metrics", len(d.Panels), len(metricDescs))
+	}
+	for i, desc := range metricDescs {
+		for _, target := range d.Panels[i].Targets {
+			if !strings.Contains(target.Expr, desc.name) {
+				t.Errorf("panel of %s queries %s", desc.name, target.Expr)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		desc  metricDesc
+		exprs []string
+	}{
+		{metricDesc{"c_total", "", counterKind, nil}, []string{"sum(rate(c_total[$__rate_interval]))"}},
+		{metricDesc{"c_total", "", counterKind, []string{"code", "cause"}}, []string{"sum by (code, cause) (rate(c_total[$__rate_interval]))"}},
+		{metricDesc{"g", "", gaugeKind, []string{"target"}}, []string{"sum by (target) (g)"}},
+		{metricDesc{"h_seconds", "", histogramKind, []string{"step"}}, []string{
+			"histogram_quantile(0.5, sum by (le, step) (rate(h_seconds_bucket[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, step) (rate(h_seconds_bucket[$__rate_interval])))",
+		}},
+	} {
+		targets := panelTargets(tc.desc)
+		if len(targets) != len(tc.exprs) {
+			t.Errorf("%s: got %v queries, want %v", tc.desc.name, len(targets), len(tc.exprs))
+			continue
+		}
+		for i, target := range targets {
+			if target.Expr != tc.exprs[i] {
+				t.Errorf("%s: got query %s, want %s", tc.desc.name, target.Expr, tc.exprs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// handleDebug registers the pprof profiles and the expvar variables, the
+// metrics included, on /debug, to profile long running bubbles for leaks.
+func handleDebug(mux *http.ServeMux, opts *options) {
+	expvar.Publish("bubble", expvar.Func(func() interface{} { return registry.snapshot() }))
+	mux.HandleFunc("/debug/vars", require(permissionRead, opts, expvar.Handler().ServeHTTP))
+	mux.HandleFunc("/debug/pprof/", require(permissionRead, opts, pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", require(permissionRead, opts, pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", require(permissionRead, opts, pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", require(permissionRead, opts, pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", require(permissionRead, opts, pprof.Trace))
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	handleDebug(mux, &options{})
+	for path, want := range map[string]string{
+		"/debug/vars":   `"bubble":`,
+		"/debug/pprof/": "goroutine",
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: got status %v, body without %s", path, w.Code, want)
+		}
+	}
+}
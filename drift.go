@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// drift compares the number of containers of each target with the number
+// bubble expects after its own creations and removals, to detect another
+// actor creating or deleting containers behind its back.
+type drift struct {
+	threshold int
+	// expected is the number of containers of each target, known once a
+	// cycle listed them.
+	expected map[string]int
+}
+
+func newDrift(threshold int) *drift {
+	return &drift{threshold: threshold, expected: map[string]int{}}
+}
+
+// observe compares the containers of the target listed by a cycle with the
+// expected ones, alerting when they differ by the threshold or more, and
+// takes them as the new expectation.
+func (d *drift) observe(log *logrus.Entry, t target, actual int) {
+	expected, ok := d.expected[t.String()]
+	d.expected[t.String()] = actual
+	if !ok {
+		return
+	}
+	diff := actual - expected
+	registry.set(metricDrift, float64(diff), label{"target", t.String()})
+	if diff < d.threshold && -diff < d.threshold {
+		return
+	}
+	log.WithField("target", t.String()).WithField("expected", expected).WithField("actual", actual).Warn("fleet drift")
+	bus.publish(busEvent{Kind: eventDrift, Err: fmt.Errorf("fleet drift of %s: %v containers expected, %v found", t, expected, actual)})
+}
+
+// expect accounts for containers bubble created and removed.
+func (d *drift) expect(t target, created, removed int) {
+	if expected, ok := d.expected[t.String()]; ok {
+		d.expected[t.String()] = expected + created - removed
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type recordingSubscriber struct {
+	events []busEvent
+}
+
+func (s *recordingSubscriber) handle(e busEvent) {
+	s.events = append(s.events, e)
+}
+
+func TestDrift(t *testing.T) {
+	recorder := &recordingSubscriber{}
+	bus.subscribe(recorder)
+	log := logrus.NewEntry(logrus.StandardLogger())
+	target := target{image: "redis"}
+	d := newDrift(2)
+	d.observe(log, target, 5)
+	d.expect(target, 2, 1)
+	// 6 expected, 1 container deleted behind bubble's back.
+	d.observe(log, target, 5)
+	// 5 expected, 2 containers created behind bubble's back.
+	d.observe(log, target, 7)
+	drifts := 0
+	for _, e := range recorder.events {
+		if e.Kind == eventDrift {
+			drifts++
+		}
+	}
+	if drifts != 1 {
+		t.Fatalf("got %v drift alerts, want 1", drifts)
+	}
+	if got := registry.snapshot()[series(metricDrift, label{"target", target.String()})]; got != 2 {
+		t.Fatalf("got drift metric %v, want 2", got)
+	}
+}
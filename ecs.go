@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ecsTargetPrefix prefixes the actions of the ecs json api.
+const ecsTargetPrefix = "AmazonEC2ContainerServiceV20141113."
+
+// ecs churns the tasks of a service of an ecs cluster through the ecs json
+// api. The requests are signed by bubble rather than with the aws sdk, which
+// would vendor a large dependency tree for four api calls.
+type ecs struct {
+	region  string
+	cluster string
+	service string
+	creds   *awsCredentialsProvider
+	client  *http.Client
+}
+
+func newECS(opts *options) *ecs {
+	return &ecs{
+		region:  opts.ecsRegion,
+		cluster: opts.ecsCluster,
+		service: opts.ecsService,
+		creds:   newAWSCredentialsProvider(),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func defaultAWSRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+func (e *ecs) String() string {
+	return e.cluster + "/" + e.service
+}
+
+// do calls the action of the ecs api and decodes its answer into out.
+func (e *ecs) do(action string, in, out interface{}) error {
+	creds, err := e.creds.get()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	host := "ecs." + e.region + ".amazonaws.com"
+	req, err := http.NewRequest(http.MethodPost, "https://"+host+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", ecsTargetPrefix+action)
+	signV4(req, body, "ecs", e.region, creds, time.Now())
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call ecs %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read ecs %s answer: %w", action, err)
+	}
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &failure)
+		return fmt.Errorf("ecs %s answered %s: %s %s", action, resp.Status, failure.Type, failure.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("could not decode ecs %s answer: %w", action, err)
+	}
+	return nil
+}
+
+func (e *ecs) count() (int, error) {
+	var out struct {
+		Services []struct {
+			DesiredCount int `json:"desiredCount"`
+		} `json:"services"`
+	}
+	in := map[string]interface{}{"cluster": e.cluster, "services": []string{e.service}}
+	if err := e.do("DescribeServices", in, &out); err != nil {
+		return 0, err
+	}
+	if len(out.Services) == 0 {
+		return 0, fmt.Errorf("ecs cluster %s has no service %s", e.cluster, e.service)
+	}
+	return out.Services[0].DesiredCount, nil
+}
+
+func (e *ecs) units() ([]string, error) {
+	arns := []string{}
+	in := map[string]interface{}{"cluster": e.cluster, "serviceName": e.service, "desiredStatus": "RUNNING"}
+	for {
+		var out struct {
+			TaskArns  []string `json:"taskArns"`
+			NextToken string   `json:"nextToken"`
+		}
+		if err := e.do("ListTasks", in, &out); err != nil {
+			return nil, err
+		}
+		arns = append(arns, out.TaskArns...)
+		if out.NextToken == "" {
+			return arns, nil
+		}
+		in["nextToken"] = out.NextToken
+	}
+}
+
+func (e *ecs) scale(count int) error {
+	return e.do("UpdateService", map[string]interface{}{"cluster": e.cluster, "service": e.service, "desiredCount": count}, nil)
+}
+
+func (e *ecs) stop(id string) error {
+	return e.do("StopTask", map[string]interface{}{"cluster": e.cluster, "task": id, "reason": "stopped by bubble"}, nil)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// containerEvent is a docker event of a container of one of the hosts.
+type containerEvent struct {
+	host    *host
+	message events.Message
+}
+
+// watchEvents sends the events of the containers of every host, among the
+// ones given, until the process exits. Events are only forwarded, they are
+// matched against the targets by the receiver, which owns them.
+func watchEvents(hosts []*host, names ...string) <-chan containerEvent {
+	out := make(chan containerEvent)
+	for _, h := range hosts {
+		go func(h *host) {
+			for {
+				if err := watchHostEvents(h, names, out); err != nil {
+					logrus.WithError(err).WithField("host", h.name).Warn("container events interrupted")
+				}
+				time.Sleep(5 * time.Second)
+				// the cached inspections of the host may have missed the
+				// events of the interruption.
+				inspections.forget(h)
+			}
+		}(h)
+	}
+	return out
+}
+
+func watchHostEvents(h *host, names []string, out chan<- containerEvent) error {
+	args := filters.NewArgs(filters.Arg("type", "container"))
+	for _, name := range names {
+		args.Add("event", name)
+	}
+	messages, errs := h.client.Events(context.Background(), types.EventsOptions{Filters: args})
+	for {
+		select {
+		case msg := <-messages:
+			out <- containerEvent{host: h, message: msg}
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// eventCandidate returns the container of the event when it belongs to one
+// of the targets, with its target.
+func eventCandidate(e containerEvent, targets []target) (candidate, target, bool, error) {
+	args := filters.NewArgs(filters.Arg("id", e.message.Actor.ID))
+	containers, err := e.host.client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return candidate{}, target{}, false, err
+	}
+	for _, container := range containers {
+		for _, t := range targets {
+			if t.matches(container) {
+				return candidate{Container: container, host: e.host}, t, true, nil
+			}
+		}
+	}
+	return candidate{}, target{}, false, nil
+}
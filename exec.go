@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// activeExecs tells if an exec session runs in the container, someone
+// debugging in it for instance.
+func activeExecs(c candidate) (bool, error) {
+	infos, err := c.host.client.ContainerInspect(context.Background(), c.ID)
+	if err != nil {
+		return false, fmt.Errorf("could not inspect container id %s: %w", c.ID, err)
+	}
+	for _, id := range infos.ExecIDs {
+		exec, err := c.host.client.ContainerExecInspect(context.Background(), id)
+		if err != nil {
+			// the exec ended between both calls.
+			continue
+		}
+		if exec.Running {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// skipBusyVictims drops the victims with an active exec session.
+func skipBusyVictims(victims []candidate) ([]candidate, error) {
+	kept := []candidate{}
+	for _, victim := range victims {
+		busy, err := activeExecs(victim)
+		if err != nil {
+			return nil, err
+		}
+		if busy {
+			logrus.WithField("container", victim.ID).WithField("host", victim.host.name).Info("skip container with an active exec session")
+			continue
+		}
+		kept = append(kept, victim)
+	}
+	return kept, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "errors"
+
+// Exit codes of bubble, for wrapping scripts and CI to tell the failures
+// apart.
+const (
+	exitCodeOK = 0
+	// exitCodeFailure is any other failure, e.g. a failed soak test.
+	exitCodeFailure = 1
+	// exitCodeConfig is an invalid command, option or config file.
+	exitCodeConfig = 2
+	// exitCodeUnreachable is a docker daemon which could not be reached.
+	exitCodeUnreachable = 3
+	// exitCodeBudget is an exceeded error budget.
+	exitCodeBudget = 4
+	// exitCodeInterrupted is a run with a duration or a maximum number of
+	// cycles stopped by a signal before its end.
+	exitCodeInterrupted = 5
+)
+
+// classifiedError is an error with the exit code of its failure class.
+type classifiedError struct {
+	code int
+	err  error
+}
+
+func (e classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e classifiedError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	return classifiedError{code: code, err: err}
+}
+
+// exitCode returns the exit code of the failure class of the error, the
+// generic failure when it has none.
+func exitCode(err error) int {
+	var classified classifiedError
+	if errors.As(err, &classified) {
+		return classified.code
+	}
+	return exitCodeFailure
+}
+
+// exitCode returns the exit code of a finished run, which passed when ok.
+func (r *runner) exitCode(ok bool) int {
+	switch {
+	case r.budget.exceeded():
+		return exitCodeBudget
+	case !ok:
+		return exitCodeFailure
+	case r.interrupted && (r.opts.duration > 0 || r.opts.maxCycles > 0):
+		return exitCodeInterrupted
+	}
+	return exitCodeOK
+}
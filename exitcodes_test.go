@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExitCode(t *testing.T) {
+	unreachable := withExitCode(exitCodeUnreachable, errors.New("could not reach docker daemon"))
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{errors.New("validation failed"), exitCodeFailure},
+		{unreachable, exitCodeUnreachable},
+		{fmt.Errorf("could not start: %w", unreachable), exitCodeUnreachable},
+		{withExitCode(exitCodeConfig, errors.New("api version too new")), exitCodeConfig},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("%v: got exit code %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		opts        options
+		budget      budget
+		interrupted bool
+		ok          bool
+		want        int
+	}{
+		{"passed", options{}, budget{}, false, true, exitCodeOK},
+		{"failed", options{duration: time.Hour}, budget{}, false, false, exitCodeFailure},
+		{"budget exceeded", options{}, budget{limit: percentValue{set: true, value: 0.1}, ops: 10, failures: 5}, false, false, exitCodeBudget},
+		{"stopped", options{}, budget{}, true, true, exitCodeOK},
+		{"interrupted", options{duration: time.Hour}, budget{}, true, true, exitCodeInterrupted},
+		{"interrupted before max cycles", options{maxCycles: 10}, budget{}, true, true, exitCodeInterrupted},
+		{"interrupted and failed", options{duration: time.Hour}, budget{}, true, false, exitCodeFailure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &runner{opts: &tc.opts, budget: tc.budget, interrupted: tc.interrupted}
+			if got := r.exitCode(tc.ok); got != tc.want {
+				t.Errorf("got exit code %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Why containers exited.
+const (
+	exitStopped = "stopped"
+	exitDied    = "died"
+)
+
+// exitTracker counts the exit codes and oom kills of the containers bubble
+// stopped or found dead, for the final report.
+type exitTracker struct {
+	mu        sync.Mutex
+	codes     map[int]int
+	oomKilled int
+	// seen are the exits already recorded, by container and finish time,
+	// since an exit can be both observed and caused by bubble.
+	seen map[string]bool
+}
+
+var exits = &exitTracker{codes: map[int]int{}, seen: map[string]bool{}}
+
+// record records the exit of the container from its state, once.
+func (e *exitTracker) record(h *host, id string, state *types.ContainerState, cause string) {
+	if state == nil {
+		return
+	}
+	key := id + " " + state.FinishedAt
+	e.mu.Lock()
+	if e.seen[key] {
+		e.mu.Unlock()
+		return
+	}
+	e.seen[key] = true
+	e.codes[state.ExitCode]++
+	if state.OOMKilled {
+		e.oomKilled++
+	}
+	e.mu.Unlock()
+	registry.inc(metricExits, label{"code", strconv.Itoa(state.ExitCode)}, label{"cause", cause})
+	logger := logrus.WithField("container", id).WithField("host", h.name).WithField("exit_code", state.ExitCode)
+	if state.OOMKilled {
+		registry.inc(metricOOMKilled, label{"host", h.name})
+		logger.Warn("container was oom killed")
+		return
+	}
+	logger.Debug("container exited")
+}
+
+// String summarizes the exits, e.g. "code 0: 12, code 137: 3, oom killed: 1".
+func (e *exitTracker) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	codes := make([]int, 0, len(e.codes))
+	for code := range e.codes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, 0, len(codes)+1)
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("code %v: %v", code, e.codes[code]))
+	}
+	parts = append(parts, fmt.Sprintf("oom killed: %v", e.oomKilled))
+	return strings.Join(parts, ", ")
+}
+
+func (e *exitTracker) empty() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.codes) == 0
+}
+
+// recordDeath records the exit of a container of the targets dying on its
+// own, as told by a die event. Oom events are only logged, the die event
+// following them tells whether the container was oom killed.
+func (r *runner) recordDeath(e containerEvent) {
+	id := e.message.Actor.ID
+	if e.message.Action == "oom" {
+		logrus.WithField("container", id).WithField("host", e.host.name).Debug("container ran out of memory")
+		return
+	}
+	if e.message.Action != "die" {
+		return
+	}
+	c, _, ok, err := eventCandidate(e, r.opts.targets)
+	if err != nil {
+		logrus.WithError(err).WithField("container", id).Warn("could not find dead container")
+		return
+	}
+	if !ok {
+		return
+	}
+	infos, err := e.host.client.ContainerInspect(context.Background(), c.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("container", id).Warn("could not inspect dead container")
+		return
+	}
+	exits.record(e.host, c.ID, infos.State, exitDied)
+}
+
+func (r *runner) printExits() {
+	if exits.empty() {
+		return
+	}
+	fmt.Fprintf(r.report(), "exits: %s\n", exits)
+}
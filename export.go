@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pushMetrics replaces the metrics of the bubble job on a prometheus
+// pushgateway.
+func pushMetrics(gateway string) error {
+	var body bytes.Buffer
+	if err := registry.writeText(&body); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url := strings.TrimSuffix(gateway, "/") + "/metrics/job/bubble"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not push metrics to %s: %w", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("could not push metrics to %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// statsd sends the metrics to a statsd server, with dogstatsd tags for
+// labels. Counters are sent as the increments since the last flush.
+type statsd struct {
+	conn net.Conn
+	last map[string]float64
+}
+
+func newStatsd(addr string) (*statsd, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to statsd %s: %w", addr, err)
+	}
+	// counters restored from the state file were already sent.
+	return &statsd{conn: conn, last: registry.snapshot()}, nil
+}
+
+func (s *statsd) flush() error {
+	kinds := map[string]string{}
+	for _, desc := range metricDescs {
+		kinds[desc.name] = desc.kind
+	}
+	var lines []string
+	for series, v := range registry.snapshot() {
+		name, tags := statsdName(series)
+		switch kinds[seriesName(series)] {
+		case counterKind:
+			if delta := v - s.last[series]; delta != 0 {
+				lines = append(lines, fmt.Sprintf("%s:%v|c%s", name, delta, tags))
+			}
+			s.last[series] = v
+		default:
+			lines = append(lines, fmt.Sprintf("%s:%v|g%s", name, v, tags))
+		}
+	}
+	for _, line := range lines {
+		if _, err := s.conn.Write([]byte(line)); err != nil {
+			return fmt.Errorf("could not send metrics to statsd: %w", err)
+		}
+	}
+	return nil
+}
+
+// statsdName turns a series into a statsd name, bubble.cycles, and its tags,
+// |#target:redis.
+func statsdName(series string) (string, string) {
+	name := strings.TrimSuffix(strings.Replace(seriesName(series), "bubble_", "bubble.", 1), "_total")
+	i := strings.Index(series, "{")
+	if i < 0 {
+		return name, ""
+	}
+	tags := []string{}
+	for _, pair := range strings.Split(strings.Trim(series[i:], "{}"), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			tags = append(tags, kv[0]+":"+strings.Trim(kv[1], `"`))
+		}
+	}
+	return name, "|#" + strings.Join(tags, ",")
+}
+
+// exportMetrics pushes the metrics to the configured sinks, once per cycle.
+func (r *runner) exportMetrics() {
+	if r.opts.pushgateway != "" {
+		if err := pushMetrics(r.opts.pushgateway); err != nil {
+			logrus.WithError(err).Warn("could not push metrics")
+		}
+	}
+	if r.statsd != nil {
+		if err := r.statsd.flush(); err != nil {
+			logrus.WithError(err).Warn("could not flush metrics")
+		}
+	}
+}
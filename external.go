@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Policies of the containers of the targets created during the run by
+// other tools than bubble.
+const (
+	externalAdopt  = "adopt"
+	externalIgnore = "ignore"
+	externalAlert  = "alert"
+)
+
+func checkExternalPolicy(policy string) error {
+	switch policy {
+	case externalAdopt, externalIgnore, externalAlert:
+		return nil
+	}
+	return fmt.Errorf("unknown external policy %q, expected %s, %s or %s", policy, externalAdopt, externalIgnore, externalAlert)
+}
+
+// externals are the containers of the targets created by other tools since
+// bubble started, the containers which were there before being the fleet
+// bubble churns.
+type externals struct {
+	policy  string
+	started time.Time
+	// seen are the ids of the external containers already reported, each is
+	// reported once.
+	seen map[string]bool
+}
+
+func newExternals(policy string, started time.Time) *externals {
+	return &externals{policy: policy, started: started, seen: map[string]bool{}}
+}
+
+// external tells if the candidate is an external container, neither a copy
+// nor a container recreated by bubble, both of which are created with a
+// correlation id.
+func (x *externals) external(c candidate) bool {
+	return c.Labels[managedLabel] != "true" && c.Labels[correlationLabel] == "" && !time.Unix(c.Created, 0).Before(x.started.Truncate(time.Second))
+}
+
+// filter reports the external candidates not reported yet and drops them,
+// unless they are adopted into the churned ones.
+func (x *externals) filter(log *logrus.Entry, candidates []candidate) []candidate {
+	kept := candidates[:0:0]
+	for _, c := range candidates {
+		if !x.external(c) {
+			kept = append(kept, c)
+			continue
+		}
+		if !x.seen[c.ID] {
+			x.seen[c.ID] = true
+			logger := log.WithField("container", c.ID).WithField("host", c.host.name).WithField("image", c.Image)
+			switch x.policy {
+			case externalAdopt:
+				logger.Info("external container adopted")
+			case externalIgnore:
+				logger.Debug("external container ignored")
+			case externalAlert:
+				logger.Warn("external container, not churned")
+				bus.publish(busEvent{Kind: eventExternal, Container: c.ID, Host: c.host.name, Image: c.Image, CycleID: cycleIDOf(log), Err: fmt.Errorf("container %s of image %s created on host %s by another tool", shortID(c.ID), c.Image, c.host.name)})
+			}
+		}
+		if x.policy == externalAdopt {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
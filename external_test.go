@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+func TestExternalsFilter(t *testing.T) {
+	started := time.Now()
+	h := &host{name: "local"}
+	candidates := []candidate{
+		{Container: types.Container{ID: "fleet", Created: started.Add(-time.Hour).Unix()}, host: h},
+		{Container: types.Container{ID: "copy", Created: started.Add(time.Minute).Unix(), Labels: map[string]string{managedLabel: "true"}}, host: h},
+		{Container: types.Container{ID: "recreated", Created: started.Add(time.Minute).Unix(), Labels: map[string]string{correlationLabel: "41d8e0c2b7a95f36"}}, host: h},
+		{Container: types.Container{ID: "external", Created: started.Add(time.Minute).Unix()}, host: h},
+	}
+	log := logrus.NewEntry(logrus.StandardLogger())
+	for policy, want := range map[string]int{externalAdopt: 4, externalIgnore: 3, externalAlert: 3} {
+		kept := newExternals(policy, started).filter(log, candidates)
+		if len(kept) != want {
+			t.Errorf("%s: got %v candidates, want %v", policy, len(kept), want)
+		}
+		for _, c := range kept {
+			if c.ID == "external" && policy != externalAdopt {
+				t.Errorf("%s: external container kept", policy)
+			}
+		}
+	}
+}
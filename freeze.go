@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// frozenSources are the sources of the targets with --freeze-source, whose
+// config is captured once and used for every copy of their target, so that
+// later changes to the source containers, e.g. docker update, do not leak
+// into the copies.
+type frozenSources struct {
+	mu sync.Mutex
+	// sources are the frozen sources by target.
+	sources map[string]frozenSource
+}
+
+type frozenSource struct {
+	source candidate
+	// infos is the encoded inspection of the source, decoded for each use
+	// so that copies never share it.
+	infos []byte
+}
+
+func newFrozenSources() *frozenSources {
+	return &frozenSources{sources: map[string]frozenSource{}}
+}
+
+func checkFreezeSource(o *options) error {
+	if o.freezeSource && o.backend != backendDocker {
+		return errors.New("--freeze-source requires the docker backend")
+	}
+	if o.freezeSource && o.fromSnapshot {
+		return errors.New("--freeze-source can not be used with --from-snapshot, snapshots are taken from the current source")
+	}
+	return nil
+}
+
+// freeze captures the source of every target with a candidate on startup,
+// picked with the copy source policy. The targets without any, e.g. added
+// later to the targets file, are frozen on their first copy.
+func (f *frozenSources) freeze(hosts []*host, targets []target, opts *options) error {
+	for _, t := range targets {
+		candidates, err := listCandidates(hosts, t, opts.states)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			logrus.WithField("target", t.String()).Warn("no source to freeze, frozen on first copy")
+			continue
+		}
+		source := pickSource(candidates, opts.copySource)
+		infos, err := source.host.client.ContainerInspect(context.Background(), source.ID)
+		if err != nil {
+			return fmt.Errorf("could not inspect container id %s: %w", source.ID, err)
+		}
+		if err := f.store(t, source, infos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *frozenSources) store(t target, source candidate, infos types.ContainerJSON) error {
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return fmt.Errorf("could not encode source config: %w", err)
+	}
+	f.mu.Lock()
+	f.sources[t.String()] = frozenSource{source: source, infos: data}
+	f.mu.Unlock()
+	logrus.WithField("target", t.String()).WithField("container", source.ID).WithField("host", source.host.name).Info("source config frozen")
+	return nil
+}
+
+// source returns the source of the plan and its inspection: the frozen ones
+// of its target, frozen now when it has none yet, or the current ones
+// without frozen sources.
+func (f *frozenSources) source(p plan) (candidate, types.ContainerJSON, error) {
+	if f == nil {
+		infos, err := inspections.inspect(p.source.host, p.source.ID)
+		if err != nil {
+			return p.source, infos, fmt.Errorf("could not inspect container id %s: %w", p.source.ID, err)
+		}
+		return p.source, infos, nil
+	}
+	f.mu.Lock()
+	frozen, ok := f.sources[p.target.String()]
+	f.mu.Unlock()
+	if !ok {
+		infos, err := p.source.host.client.ContainerInspect(context.Background(), p.source.ID)
+		if err != nil {
+			return p.source, infos, fmt.Errorf("could not inspect container id %s: %w", p.source.ID, err)
+		}
+		return p.source, infos, f.store(p.target, p.source, infos)
+	}
+	var infos types.ContainerJSON
+	if err := json.Unmarshal(frozen.infos, &infos); err != nil {
+		return frozen.source, infos, fmt.Errorf("could not decode source config: %w", err)
+	}
+	return frozen.source, infos, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+)
+
+func TestFrozenSources(t *testing.T) {
+	memory := map[string]int64{"first": 1 << 30, "second": 2 << 30}
+	m := &mockClient{
+		InspectFunc: func(id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: id, HostConfig: &ac.HostConfig{Resources: ac.Resources{Memory: memory[id]}}},
+				Config:            &ac.Config{Image: "redis", Labels: map[string]string{"source": id}},
+			}, nil
+		},
+	}
+	h := mockHost(m)
+	redis := target{image: "redis"}
+	first := plan{target: redis, source: candidate{Container: types.Container{ID: "first"}, host: h}}
+	second := plan{target: redis, source: candidate{Container: types.Container{ID: "second"}, host: h}}
+
+	f := newFrozenSources()
+	tests := []struct {
+		plan   plan
+		want   string
+		memory int64
+	}{
+		{first, "first", 1 << 30},
+		{second, "first", 1 << 30},
+		{second, "first", 1 << 30},
+	}
+	for i, test := range tests {
+		source, infos, err := f.source(test.plan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source.ID != test.want || infos.Config.Labels["source"] != test.want || infos.HostConfig.Memory != test.memory {
+			t.Errorf("%v: source %s with memory %v, want %s with %v", i, source.ID, infos.HostConfig.Memory, test.want, test.memory)
+		}
+		// copies must not share the frozen config.
+		infos.Config.Labels["source"], infos.HostConfig.Memory = "mutated", 0
+	}
+	if got := m.Calls(); got != "inspect first" {
+		t.Errorf("calls = %q, want the source inspected once", got)
+	}
+
+	var unfrozen *frozenSources
+	if source, _, err := unfrozen.source(second); err != nil || source.ID != "second" {
+		t.Errorf("without frozen sources, source %s, %v, want second", source.ID, err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fuzzedLabel marks the copies created with a perturbed config.
+const fuzzedLabel = "bubble.fuzzed"
+
+// fuzzSpec perturbs the config of a share of the copies to flush out the
+// assumptions of the application about its runtime environment: the memory
+// limit is jittered, the environment is shuffled and random labels are
+// added.
+func fuzzSpec(spec *copySpec, opts *options) {
+	if !opts.fuzzRate.set || rand.Float64() >= opts.fuzzRate.value {
+		return
+	}
+	spec.Config.Labels[fuzzedLabel] = "true"
+	rand.Shuffle(len(spec.Config.Env), func(i, j int) {
+		spec.Config.Env[i], spec.Config.Env[j] = spec.Config.Env[j], spec.Config.Env[i]
+	})
+	for i := rand.Intn(3); i >= 0; i-- {
+		spec.Config.Labels[fmt.Sprintf("bubble.fuzz.%s", randomSuffix())] = randomSuffix()
+	}
+	if h := spec.HostConfig; h != nil && h.Memory > 0 && opts.fuzzMemoryJitter.value > 0 {
+		jitter := opts.fuzzMemoryJitter.value * (2*rand.Float64() - 1)
+		h.Memory = int64(float64(h.Memory) * (1 + jitter))
+		if h.MemoryReservation > h.Memory {
+			h.MemoryReservation = h.Memory
+		}
+		if h.MemorySwap > 0 && h.MemorySwap < h.Memory {
+			h.MemorySwap = h.Memory
+		}
+	}
+	logrus.WithField("name", spec.Name).Info("fuzz copy config")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// collectGarbage removes the copies of the target which exited or died more
+// than gc age ago.
+func collectGarbage(hosts []*host, t target, age time.Duration, budget *budget) error {
+	args := filters.NewArgs(
+		filters.Arg("status", "exited"),
+		filters.Arg("status", "dead"),
+		filters.Arg("label", managedLabel),
+	)
+	for _, h := range hosts {
+		containers, err := h.client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: args})
+		if err != nil {
+			return fmt.Errorf("could not get the list of stopped containers of host %s: %w", h.name, err)
+		}
+		for _, container := range containers {
+			if !t.matches(container) {
+				continue
+			}
+			infos, err := h.client.ContainerInspect(context.Background(), container.ID)
+			if err != nil {
+				return fmt.Errorf("could not inspect container id %s: %w", container.ID, err)
+			}
+			finished, err := time.Parse(time.RFC3339Nano, infos.State.FinishedAt)
+			if err != nil || time.Since(finished) < age {
+				continue
+			}
+			err = h.client.ContainerRemove(context.Background(), container.ID, types.ContainerRemoveOptions{})
+			if err != nil {
+				err = fmt.Errorf("could not remove stopped container id %s: %w", container.ID, err)
+			} else {
+				logrus.WithField("container", container.ID).WithField("host", h.name).WithField("state", container.State).Info("collect container")
+				exits.record(h, container.ID, infos.State, exitDied)
+				hostPorts.release(h, container.ID)
+			}
+			if err := budget.record(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
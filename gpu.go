@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	ac "github.com/docker/docker/api/types/container"
+)
+
+const (
+	gpuShare      = "share"
+	gpuRoundRobin = "round-robin"
+	gpuStrip      = "strip"
+)
+
+// gpuCursor is the index of the next gpu handed out in round-robin.
+var gpuCursor int
+
+func checkGPUPolicy(policy string) error {
+	switch policy {
+	case gpuShare, gpuRoundRobin, gpuStrip:
+		return nil
+	}
+	return fmt.Errorf("unknown gpu policy %q, expected %s, %s or %s", policy, gpuShare, gpuRoundRobin, gpuStrip)
+}
+
+func isGPURequest(request ac.DeviceRequest) bool {
+	if request.Driver == "nvidia" {
+		return true
+	}
+	for _, capabilities := range request.Capabilities {
+		for _, capability := range capabilities {
+			if capability == "gpu" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prepareDeviceRequests applies the gpu policy to the device requests of a
+// copy. With round-robin each copy gets a single gpu, taken in turn from
+// --gpu-ids or from the ids requested by the source.
+func prepareDeviceRequests(h *ac.HostConfig, opts *options) error {
+	if h == nil || len(h.DeviceRequests) == 0 {
+		return nil
+	}
+	requests := h.DeviceRequests[:0]
+	for _, request := range h.DeviceRequests {
+		if !isGPURequest(request) {
+			requests = append(requests, request)
+			continue
+		}
+		switch opts.gpuPolicy {
+		case gpuStrip:
+			continue
+		case gpuRoundRobin:
+			ids := opts.gpuIDs
+			if len(ids) == 0 {
+				ids = request.DeviceIDs
+			}
+			if len(ids) == 0 {
+				return fmt.Errorf("round-robin gpu policy needs --gpu-ids when the source does not request device ids")
+			}
+			request.DeviceIDs = []string{ids[gpuCursor%len(ids)]}
+			request.Count = 0
+			gpuCursor++
+		}
+		requests = append(requests, request)
+	}
+	h.DeviceRequests = requests
+	return nil
+}
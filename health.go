@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+)
+
+const unhealthyAction = "health_status: unhealthy"
+
+// replaceUnhealthy replaces the container of the event when it turned
+// unhealthy and belongs to a target, going through the same steps as a cycle:
+// a copy is created on its host and the container is deleted, as the mode,
+// the minimum age, the lease and the other cycle options allow.
+func (r *runner) replaceUnhealthy(e containerEvent) error {
+	if e.message.Action != unhealthyAction {
+		return nil
+	}
+	c, t, ok, err := eventCandidate(e, r.opts.targets)
+	if err != nil || !ok {
+		return err
+	}
+	if r.externals != nil && len(r.externals.filter(r.log, []candidate{c})) == 0 {
+		return nil
+	}
+	logger := r.log.WithField("container", c.ID).WithField("host", c.host.name)
+	logger.Warn("container unhealthy, replacing it")
+	candidates, err := r.listCandidates(t)
+	if err != nil {
+		return err
+	}
+	if r.externals != nil {
+		candidates = r.externals.filter(r.log, candidates)
+	}
+	if r.leases != nil {
+		if err := r.leases.acquire(r.hosts, t, leaseImage(t, candidates), r.opts); err != nil {
+			return err
+		}
+	}
+	eligible, err := eligibleHosts(r.hosts, r.opts.constraints)
+	if err != nil {
+		return err
+	}
+	p := plan{
+		target: t,
+		source: c,
+		number: nextComposeNumber(candidates),
+	}
+	if r.opts.mode != modeDown {
+		p.targets = []*host{c.host}
+		if !containsHost(eligible, c.host) {
+			p.targets = pickHosts(eligible, 1, r.opts.spread)
+		}
+	}
+	if r.opts.mode != modeUp {
+		p.victims = deletableCandidates([]candidate{c}, r.opts.minAge, time.Now())
+	}
+	if err := r.runPlan(p); err != nil {
+		return err
+	}
+	bus.publish(busEvent{Kind: eventContainerReplaced, Cycle: r.stats.cycles, Container: c.ID, Host: c.host.name, Image: c.Image, CycleID: cycleIDOf(r.log), CorrelationID: correlationID(c.Labels)})
+	return nil
+}
+
+func containsHost(hosts []*host, h *host) bool {
+	for _, candidate := range hosts {
+		if candidate == h {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	ac "github.com/docker/docker/api/types/container"
+)
+
+// prepareHealthcheck keeps the healthcheck of the source, which already
+// carries the one of the image, and applies the overrides.
+func prepareHealthcheck(c *ac.Config, opts *options) {
+	if c == nil || opts.healthcheckCmd == "" && opts.healthcheckInterval == 0 {
+		return
+	}
+	if c.Healthcheck == nil {
+		c.Healthcheck = &ac.HealthConfig{}
+	}
+	if opts.healthcheckCmd != "" {
+		c.Healthcheck.Test = []string{"CMD-SHELL", opts.healthcheckCmd}
+	}
+	if opts.healthcheckInterval != 0 {
+		c.Healthcheck.Interval = opts.healthcheckInterval
+	}
+}
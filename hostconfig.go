@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+// strippers are the host config fields which can be removed from copies
+// with --strip.
+var strippers = map[string]func(*ac.HostConfig){
+	"devices": func(h *ac.HostConfig) {
+		h.Devices = nil
+		h.DeviceCgroupRules = nil
+	},
+	"ulimits": func(h *ac.HostConfig) { h.Ulimits = nil },
+	"sysctls": func(h *ac.HostConfig) { h.Sysctls = nil },
+	"tmpfs":   func(h *ac.HostConfig) { h.Tmpfs = nil },
+	"capabilities": func(h *ac.HostConfig) {
+		h.CapAdd = nil
+		h.CapDrop = nil
+	},
+	"security-opt": func(h *ac.HostConfig) { h.SecurityOpt = nil },
+	"seccomp":      func(h *ac.HostConfig) { h.SecurityOpt = withoutSecurityOpt(h.SecurityOpt, "seccomp") },
+	"apparmor":     func(h *ac.HostConfig) { h.SecurityOpt = withoutSecurityOpt(h.SecurityOpt, "apparmor") },
+	"privileged":   func(h *ac.HostConfig) { h.Privileged = false },
+}
+
+// withoutSecurityOpt removes the security options of the kind, written
+// kind=value or kind:value.
+func withoutSecurityOpt(opts []string, kind string) []string {
+	kept := []string{}
+	for _, opt := range opts {
+		if !strings.HasPrefix(opt, kind+"=") && !strings.HasPrefix(opt, kind+":") {
+			kept = append(kept, opt)
+		}
+	}
+	return kept
+}
+
+// warnUnconfined warns when the copy is going to be created privileged or
+// without its security profiles, since copies replicate these silently.
+func warnUnconfined(h *ac.HostConfig) {
+	if h.Privileged {
+		logrus.Warn("copy is created privileged, --strip privileged to drop it")
+	}
+	for _, c := range h.CapAdd {
+		if c == "ALL" || c == "CAP_ALL" || c == "SYS_ADMIN" || c == "CAP_SYS_ADMIN" {
+			logrus.WithField("capability", c).Warn("copy is created with a privileged capability, --strip capabilities to drop it")
+		}
+	}
+	for _, opt := range h.SecurityOpt {
+		if strings.HasSuffix(opt, "=unconfined") || strings.HasSuffix(opt, ":unconfined") {
+			logrus.WithField("security_opt", opt).Warn("copy is created unconfined")
+		}
+	}
+}
+
+func stripperNames() []string {
+	names := make([]string, 0, len(strippers))
+	for name := range strippers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkStrip(fields []string) error {
+	for _, field := range fields {
+		if _, ok := strippers[field]; !ok {
+			return fmt.Errorf("unknown field %q to strip, expected one of %s", field, strings.Join(stripperNames(), ","))
+		}
+	}
+	return nil
+}
+
+// prepareHostConfig strips the requested fields and checks the remaining
+// ones can be used to create a new container.
+func prepareHostConfig(h *ac.HostConfig, opts *options) error {
+	if h == nil {
+		return nil
+	}
+	for _, field := range opts.strip {
+		strippers[field](h)
+	}
+	warnUnconfined(h)
+	if opts.restartPolicy != nil {
+		h.RestartPolicy = *opts.restartPolicy
+	}
+	if opts.cgroupParent != "" {
+		h.CgroupParent = opts.cgroupParent
+	}
+	if opts.runtime != "" {
+		h.Runtime = opts.runtime
+	}
+	for i, device := range h.Devices {
+		if device.PathOnHost == "" {
+			return fmt.Errorf("device %v has no path on host", i)
+		}
+		if device.PathInContainer == "" {
+			h.Devices[i].PathInContainer = device.PathOnHost
+		}
+		if strings.Trim(device.CgroupPermissions, "rwm") != "" {
+			return fmt.Errorf("device %s has invalid cgroup permissions %q", device.PathOnHost, device.CgroupPermissions)
+		}
+	}
+	for _, ulimit := range h.Ulimits {
+		if ulimit.Soft > ulimit.Hard && ulimit.Hard >= 0 {
+			return fmt.Errorf("ulimit %s has soft limit %v above hard limit %v", ulimit.Name, ulimit.Soft, ulimit.Hard)
+		}
+	}
+	for dest := range h.Tmpfs {
+		if !path.IsAbs(dest) {
+			return fmt.Errorf("tmpfs destination %q is not absolute", dest)
+		}
+	}
+	logrus.WithField("devices", len(h.Devices)).
+		WithField("ulimits", len(h.Ulimits)).
+		WithField("sysctls", len(h.Sysctls)).
+		WithField("tmpfs", len(h.Tmpfs)).
+		WithField("cap_add", len(h.CapAdd)).
+		WithField("cap_drop", len(h.CapDrop)).
+		WithField("security_opt", len(h.SecurityOpt)).
+		WithField("privileged", h.Privileged).
+		WithField("cgroup_parent", h.CgroupParent).
+		WithField("runtime", h.Runtime).
+		Debug("clone host config")
+	return nil
+}
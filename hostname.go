@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// copySuffix matches the suffix given to copies, so that copies of copies do
+// not accumulate suffixes.
+var copySuffix = regexp.MustCompile(`-[0-9a-f]{6}$`)
+
+// copyName derives the name of a copy from the name of its source. The
+// suffix is only replaced when the source is itself a copy, names of other
+// containers may end like a suffix.
+func copyName(sourceName, suffix string, sourceManaged bool) string {
+	name := strings.TrimPrefix(sourceName, "/")
+	if sourceManaged {
+		name = copySuffix.ReplaceAllString(name, "")
+	}
+	return name + "-" + suffix
+}
+
+// hostnameData is what the hostname template is executed with.
+type hostnameData struct {
+	Name   string
+	Source string
+	Suffix string
+	Image  string
+}
+
+func parseHostnameTemplate(text string) (*template.Template, error) {
+	t, err := template.New("hostname").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostname template: %w", err)
+	}
+	return t, nil
+}
+
+// prepareHostname replaces the hostname inherited from the source by the
+// templated one, except when the network namespace is shared with another
+// container.
+func prepareHostname(spec *copySpec, data hostnameData, opts *options) error {
+	if spec.HostConfig != nil && spec.HostConfig.NetworkMode.IsContainer() {
+		return nil
+	}
+	t, err := parseHostnameTemplate(opts.hostnameTemplate)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return fmt.Errorf("could not execute hostname template: %w", err)
+	}
+	hostname := strings.Trim(strings.ToLower(b.String()), "-.")
+	if len(hostname) > 63 {
+		hostname = strings.TrimRight(hostname[:63], "-.")
+	}
+	spec.Config.Hostname = hostname
+	return nil
+}
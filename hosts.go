@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	spreadRoundRobin = "round-robin"
+	spreadWeighted   = "weighted"
+)
+
+// host is a docker daemon bubble churns containers on.
+type host struct {
+	name   string
+	client dockerClient
+	weight int
+	// ostype and arch are the platform of the daemon, fetched on first
+	// use.
+	ostype string
+	arch   string
+	// rootless and cgroupVersion tell what the daemon can honor, fetched
+	// with the platform.
+	rootless      bool
+	cgroupVersion string
+}
+
+// newHosts connects to every --host, or to the local daemon when there are
+// none. The api version is negotiated with each
+// daemon unless pinned.
+func newHosts(opts *options) ([]*host, error) {
+	urls := opts.hosts
+	if len(urls) == 0 {
+		urls = []string{localDaemon(opts)}
+	}
+	hosts := []*host{}
+	for i, url := range urls {
+		clientOpts := []client.Opt{client.FromEnv}
+		if url != "" {
+			clientOpts = append(clientOpts, client.WithHost(url))
+		}
+		if opts.apiVersion != "" {
+			clientOpts = append(clientOpts, client.WithVersion(opts.apiVersion))
+		} else {
+			clientOpts = append(clientOpts, client.WithAPIVersionNegotiation())
+		}
+		recordingOpt, err := recording(opts, url)
+		if err != nil {
+			closeHosts(hosts)
+			return nil, err
+		}
+		if recordingOpt != nil {
+			clientOpts = append(clientOpts, recordingOpt)
+		}
+		c, err := client.NewClientWithOpts(clientOpts...)
+		if err != nil {
+			closeHosts(hosts)
+			return nil, fmt.Errorf("could not create client for host %s: %w", url, err)
+		}
+		if url == "" {
+			url = c.DaemonHost()
+		}
+		weight := 1
+		if i < len(opts.weights) {
+			weight = opts.weights[i]
+		}
+		hosts = append(hosts, &host{name: url, client: c, weight: weight})
+	}
+	return hosts, nil
+}
+
+// checkAPIVersion pings the daemon, which negotiates the api version, and
+// fails clearly when the pinned version is not supported by the daemon.
+func checkAPIVersion(h *host) (types.Ping, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ping, err := h.client.Ping(ctx)
+	if err != nil {
+		return ping, withExitCode(exitCodeUnreachable, fmt.Errorf("could not reach docker daemon %s: %w", h.name, err))
+	}
+	if ping.APIVersion != "" && versions.GreaterThan(h.client.ClientVersion(), ping.APIVersion) {
+		return ping, withExitCode(exitCodeConfig, fmt.Errorf("api version %s is too new for docker daemon %s, which supports up to %s", h.client.ClientVersion(), h.name, ping.APIVersion))
+	}
+	return ping, nil
+}
+
+func closeHosts(hosts []*host) {
+	for _, h := range hosts {
+		h.client.Close()
+	}
+}
+
+func checkSpread(opts *options) error {
+	if opts.spread != spreadRoundRobin && opts.spread != spreadWeighted {
+		return fmt.Errorf("unknown spread %q, expected %s or %s", opts.spread, spreadRoundRobin, spreadWeighted)
+	}
+	if len(opts.weights) > len(opts.hosts) {
+		return fmt.Errorf("%v weights given for %v hosts", len(opts.weights), len(opts.hosts))
+	}
+	for _, weight := range opts.weights {
+		if weight <= 0 {
+			return fmt.Errorf("host weight must be positive, got %v", weight)
+		}
+	}
+	return nil
+}
+
+// spreadCursor is the index of the next host in round-robin.
+var spreadCursor int
+
+// pickHosts chooses the host of each of the n copies.
+func pickHosts(hosts []*host, n uint64, spread string) []*host {
+	if len(hosts) == 0 {
+		return nil
+	}
+	total := 0
+	for _, h := range hosts {
+		total += h.weight
+	}
+	targets := make([]*host, 0, n)
+	for i := uint64(0); i < n; i++ {
+		if spread == spreadRoundRobin {
+			targets = append(targets, hosts[spreadCursor%len(hosts)])
+			spreadCursor++
+			continue
+		}
+		r := rand.Intn(total)
+		for _, h := range hosts {
+			if r < h.weight {
+				targets = append(targets, h)
+				break
+			}
+			r -= h.weight
+		}
+	}
+	return targets
+}
+
+// eligibleHosts returns the hosts satisfying the placement constraints.
+func eligibleHosts(hosts []*host, constraints []string) ([]*host, error) {
+	eligible := []*host{}
+	for _, h := range hosts {
+		unmet, err := unmetConstraints(h.client, constraints)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.name, err)
+		}
+		if len(unmet) > 0 {
+			logrus.WithField("host", h.name).WithField("constraints", strings.Join(unmet, ",")).Debug("host does not satisfy placement constraints")
+			continue
+		}
+		eligible = append(eligible, h)
+	}
+	return eligible, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// correlationLabel holds the correlation id of a copy, which follows its
+// creation and its removal.
+const correlationLabel = "bubble.correlation-id"
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cycleLogger returns the logger of a new cycle, which tags every line with
+// the id of the cycle. It is passed down to what the cycle does rather than
+// set globally, so that the lines of the other goroutines are not tagged.
+func cycleLogger(logger *logrus.Logger) *logrus.Entry {
+	return logger.WithField("cycle_id", newID())
+}
+
+// cycleIDOf returns the id of the cycle of the logger, empty outside cycles.
+func cycleIDOf(log *logrus.Entry) string {
+	id, _ := log.Data["cycle_id"].(string)
+	return id
+}
+
+// correlationID returns the correlation id of the container from its
+// labels, or a new one for containers bubble did not create.
+func correlationID(labels map[string]string) string {
+	if id := labels[correlationLabel]; id != "" {
+		return id
+	}
+	return newID()
+}
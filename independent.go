@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkIndependent parses --add and --remove, which come with their own
+// interval, creations and deletions then following independent rhythms
+// instead of the ratio.
+func checkIndependent(o *options) error {
+	o.addRatio, o.removeRatio = RatioValue{}, RatioValue{}
+	var err error
+	if o.addRatio.Up, o.addRatio.UpPercent, err = parseIndependent("add", o.add, o.addEvery); err != nil {
+		return err
+	}
+	if o.removeRatio.Down, o.removeRatio.DownPercent, err = parseIndependent("remove", o.remove, o.removeEvery); err != nil {
+		return err
+	}
+	if o.removeRatio.DownPercent && o.removeRatio.Down > 100 {
+		return fmt.Errorf("can not remove more than 100%% of the containers, got %v%%", o.removeRatio.Down)
+	}
+	if len(o.windows) > 0 && (o.addEvery > 0 || o.removeEvery > 0) {
+		return fmt.Errorf("--add and --remove can not be used with a schedule")
+	}
+	return nil
+}
+
+func parseIndependent(name, count string, every time.Duration) (uint64, bool, error) {
+	if count == "" && every == 0 {
+		return 0, false, nil
+	}
+	if count == "" || every <= 0 {
+		return 0, false, fmt.Errorf("--%s requires a positive --%s-every and the other way around", name, name)
+	}
+	n, percent, err := parseRatioSide(count)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid --%s %q: %w", name, count, err)
+	}
+	return n, percent, nil
+}
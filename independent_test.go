@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckIndependent(t *testing.T) {
+	for _, tc := range []struct {
+		add, remove           string
+		addEvery, removeEvery time.Duration
+		ok                    bool
+		want                  [2]RatioValue
+	}{
+		{"", "", 0, 0, true, [2]RatioValue{}},
+		{"3", "10%", time.Minute, 5 * time.Minute, true, [2]RatioValue{{Up: 3}, {Down: 10, DownPercent: true}}},
+		{"2", "", time.Minute, 0, true, [2]RatioValue{{Up: 2}, {}}},
+		{"2", "", 0, 0, false, [2]RatioValue{}},
+		{"", "", time.Minute, 0, false, [2]RatioValue{}},
+		{"x", "", time.Minute, 0, false, [2]RatioValue{}},
+		{"", "120%", 0, time.Minute, false, [2]RatioValue{}},
+	} {
+		o := &options{add: tc.add, remove: tc.remove, addEvery: tc.addEvery, removeEvery: tc.removeEvery}
+		err := checkIndependent(o)
+		if (err == nil) != tc.ok {
+			t.Errorf("%+v: got error %v, want ok %v", tc, err, tc.ok)
+			continue
+		}
+		if tc.ok && (o.addRatio != tc.want[0] || o.removeRatio != tc.want[1]) {
+			t.Errorf("%+v: got %+v and %+v", tc, o.addRatio, o.removeRatio)
+		}
+	}
+}
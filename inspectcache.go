@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// inspectEvents are the container events which change what an inspect
+// returns, invalidating the cached inspections of their container.
+var inspectEvents = []string{"start", "die", "rename", "update", "destroy"}
+
+// inspectCache caches the inspections of the sources of copies, so that
+// cycles copying the same source do not inspect it again. Entries are
+// invalidated by the container events and expire after the ttl, since
+// network connections are not container events, and the whole host is
+// forgotten while its events are interrupted.
+type inspectCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[*host]map[string]inspection
+}
+
+type inspection struct {
+	infos types.ContainerJSON
+	at    time.Time
+}
+
+var inspections = &inspectCache{entries: map[*host]map[string]inspection{}}
+
+// inspect returns the inspection of the container, from the cache when it is
+// enabled and holds a fresh one.
+func (c *inspectCache) inspect(h *host, id string) (types.ContainerJSON, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[h][id]
+	ttl := c.ttl
+	c.mu.Unlock()
+	if ok && time.Since(entry.at) < ttl {
+		return entry.infos, nil
+	}
+	began := time.Now()
+	infos, err := h.client.ContainerInspect(context.Background(), id)
+	timeStep(stepInspect, began)
+	if err != nil || ttl <= 0 {
+		return infos, err
+	}
+	c.mu.Lock()
+	if c.entries[h] == nil {
+		c.entries[h] = map[string]inspection{}
+	}
+	c.entries[h][id] = inspection{infos: infos, at: began}
+	c.mu.Unlock()
+	return infos, nil
+}
+
+// enable caches the inspections for the ttl, only when the container events
+// invalidating them are watched.
+func (c *inspectCache) enable(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+// invalidate forgets the inspection of the container, both its id and the
+// name under which it may have been inspected.
+func (c *inspectCache) invalidate(h *host, id string) {
+	c.mu.Lock()
+	for key, entry := range c.entries[h] {
+		if key == id || entry.infos.ID == id {
+			delete(c.entries[h], key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// forget forgets every inspection of the host.
+func (c *inspectCache) forget(h *host) {
+	c.mu.Lock()
+	delete(c.entries, h)
+	c.mu.Unlock()
+}
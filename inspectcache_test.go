@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestInspectCacheInvalidate(t *testing.T) {
+	h := &host{name: "local"}
+	c := &inspectCache{ttl: time.Minute, entries: map[*host]map[string]inspection{
+		h: {
+			"redis-1":  {infos: types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "a"}}, at: time.Now()},
+			"b":        {infos: types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "b"}}, at: time.Now()},
+			"stale-id": {infos: types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "c"}}, at: time.Now().Add(-time.Hour)},
+		},
+	}}
+	c.invalidate(h, "a")
+	if _, ok := c.entries[h]["redis-1"]; ok {
+		t.Error("inspection by name not invalidated by the id")
+	}
+	if _, ok := c.entries[h]["b"]; !ok {
+		t.Error("inspection of another container invalidated")
+	}
+	// a fresh entry is served without calling the daemon, the host has no
+	// client.
+	if infos, err := c.inspect(h, "b"); err != nil || infos.ID != "b" {
+		t.Errorf("got %v, %v, want the cached inspection", infos.ContainerJSONBase, err)
+	}
+	c.forget(h)
+	if len(c.entries[h]) != 0 {
+		t.Error("inspections of the host not forgotten")
+	}
+}
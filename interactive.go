@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm prints the question on stderr and waits for the operator answer,
+// anything else than y or yes is a refusal.
+func confirm(format string, args ...interface{}) (bool, error) {
+	fmt.Fprintf(os.Stderr, format+" [y/N] ", args...)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("could not read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
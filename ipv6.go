@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+)
+
+// copyIPv6 returns the static IPv6 address of the copy on the endpoint of
+// its source: a new address in the subnet of the static address of the
+// source, so that it keeps one without colliding with it, or none when the
+// source has none or with --strip-ipv6, the daemon then allocating it.
+func copyIPv6(source *network.EndpointSettings, opts *options) string {
+	if opts.stripIPv6 || source.IPAMConfig == nil || source.IPAMConfig.IPv6Address == "" {
+		return ""
+	}
+	prefix := source.GlobalIPv6PrefixLen
+	if prefix == 0 {
+		prefix = 64
+	}
+	address, err := regenerateIPv6(source.IPAMConfig.IPv6Address, prefix)
+	if err != nil {
+		logrus.WithError(err).WithField("address", source.IPAMConfig.IPv6Address).Warn("could not regenerate static ipv6 address, stripped")
+		return ""
+	}
+	return address
+}
+
+// regenerateIPv6 returns a random address of the subnet of the address,
+// other than the address itself and the subnet address.
+func regenerateIPv6(address string, prefix int) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To4() != nil {
+		return "", fmt.Errorf("invalid ipv6 address %q", address)
+	}
+	if prefix < 0 || prefix >= 127 {
+		return "", fmt.Errorf("invalid ipv6 prefix length %v", prefix)
+	}
+	mask := net.CIDRMask(prefix, 128)
+	subnet := ip.Mask(mask)
+	for {
+		random := make(net.IP, net.IPv6len)
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		generated := make(net.IP, net.IPv6len)
+		for i := range generated {
+			generated[i] = subnet[i] | random[i]&^mask[i]
+		}
+		if !generated.Equal(ip) && !generated.Equal(subnet) {
+			return generated.String(), nil
+		}
+	}
+}
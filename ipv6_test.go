@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestRegenerateIPv6(t *testing.T) {
+	for _, tc := range []struct {
+		address string
+		prefix  int
+		ok      bool
+	}{
+		{"fd00:dead:beef::10", 64, true},
+		{"2001:db8::1", 126, true},
+		{"2001:db8::1", 0, true},
+		{"10.0.0.1", 24, false},
+		{"not an ip", 64, false},
+		{"2001:db8::1", 127, false},
+	} {
+		for i := 0; i < 100; i++ {
+			got, err := regenerateIPv6(tc.address, tc.prefix)
+			if (err == nil) != tc.ok {
+				t.Fatalf("%s/%v: got error %v", tc.address, tc.prefix, err)
+			}
+			if err != nil {
+				break
+			}
+			_, subnet, _ := net.ParseCIDR(tc.address + "/" + strconv.Itoa(tc.prefix))
+			ip := net.ParseIP(got)
+			if ip == nil || !subnet.Contains(ip) || ip.Equal(net.ParseIP(tc.address)) || ip.Equal(subnet.IP) {
+				t.Fatalf("%s/%v: got %s, want another address of %s", tc.address, tc.prefix, got, subnet)
+			}
+		}
+	}
+}
+
+func TestCopyIPv6(t *testing.T) {
+	static := &network.EndpointSettings{IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "172.18.0.5", IPv6Address: "fd00::5"}, GlobalIPv6PrefixLen: 64}
+	if got := copyIPv6(static, &options{}); got == "" || got == "fd00::5" {
+		t.Errorf("got address %q, want a new one", got)
+	}
+	if got := copyIPv6(static, &options{stripIPv6: true}); got != "" {
+		t.Errorf("got address %q with --strip-ipv6, want none", got)
+	}
+	if got := copyIPv6(&network.EndpointSettings{GlobalIPv6Address: "fd00::6"}, &options{}); got != "" {
+		t.Errorf("got address %q for a dynamic address, want none", got)
+	}
+}
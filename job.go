@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// Container states candidates can be in.
+const (
+	stateRunning = "running"
+	statePaused  = "paused"
+	stateExited  = "exited"
+)
+
+// candidate is a container matching the image on one of the hosts.
+type candidate struct {
+	types.Container
+	host *host
+}
+
+// plan is what a single cycle is going to do.
+type plan struct {
+	target  target
+	source  candidate
+	targets []*host
+	victims []candidate
+	// number is the compose container number of the first copy.
+	number int
+	// image replaces the image of the copies created on the source host.
+	image string
+}
+
+func (p plan) String() string {
+	return fmt.Sprintf("create %v copies of %s, delete %v containers [%s]",
+		len(p.targets), shortID(p.source.ID), len(p.victims), strings.Join(victimIDs(p), ","))
+}
+
+func victimIDs(p plan) []string {
+	ids := make([]string, 0, len(p.victims))
+	for _, victim := range p.victims {
+		ids = append(ids, shortID(victim.ID))
+	}
+	return ids
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// listCandidates lists the containers of the target in one of the states on
+// every host. The daemons filter the containers, so that hosts with
+// thousands of them do not send them all, and the matches are checked again
+// since ancestor filters match the images built from the image of the target
+// too.
+func listCandidates(hosts []*host, t target, states []string) ([]candidate, error) {
+	all := false
+	for _, state := range states {
+		if state != stateRunning {
+			all = true
+		}
+	}
+	candidates := []candidate{}
+	for _, h := range hosts {
+		seen := map[string]bool{}
+		for _, args := range candidateFilters(t) {
+			for _, state := range states {
+				args.Add("status", state)
+			}
+			began := time.Now()
+			containers, err := h.client.ContainerList(context.Background(), types.ContainerListOptions{All: all, Filters: args})
+			timeStep(stepList, began)
+			if err != nil {
+				return nil, fmt.Errorf("could not get the list of containers of host %s: %w", h.name, err)
+			}
+			for _, container := range containers {
+				if !seen[container.ID] && t.matches(container) {
+					seen[container.ID] = true
+					candidates = append(candidates, candidate{Container: container, host: h})
+				}
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		logrus.WithField("container", candidate.ID).WithField("image", candidate.Image).WithField("host", candidate.host.name).Debug("found container")
+	}
+	return candidates, nil
+}
+
+// candidateFilters returns the filters listing the containers of the
+// target, one list per filter: its copies, labeled with the target, and the
+// containers of its image, or of its compose service. Unknown images are
+// ignored by the ancestor filter of the daemon.
+func candidateFilters(t target) []filters.Args {
+	copies := filters.NewArgs(filters.Arg("label", targetLabel+"="+t.String()))
+	others := filters.NewArgs(filters.Arg("ancestor", t.image))
+	if t.service != "" {
+		others = filters.NewArgs(
+			filters.Arg("label", composeProjectLabel+"="+t.project),
+			filters.Arg("label", composeServiceLabel+"="+t.service),
+		)
+	}
+	for key, value := range t.scope {
+		copies.Add("label", key+"="+value)
+		others.Add("label", key+"="+value)
+	}
+	if t.network != "" {
+		copies.Add("network", t.network)
+		others.Add("network", t.network)
+	}
+	return []filters.Args{copies, others}
+}
+
+// makePlan picks the source to copy with the copy source policy, the hosts
+// of the copies among the eligible ones, and distinct victims drawn with the
+// victim strategy among the candidates old enough, with the paused policy.
+// Candidates must not be empty.
+func makePlan(t target, candidates []candidate, eligible []*host, ratio RatioValue, opts *options) (plan, error) {
+	up, down := ratio.Up, ratio.Down
+	switch opts.mode {
+	case modeUp:
+		down = 0
+	case modeDown:
+		up = 0
+	}
+	deletable := deletableCandidates(candidates, opts.minAge, time.Now())
+	if opts.pausedPolicy == pausedSkip {
+		_, deletable = splitPaused(deletable)
+	}
+	if int(down) > len(deletable) {
+		return plan{}, fmt.Errorf("can not delete %v containers when exists only %v old enough", down, len(deletable))
+	}
+	p := plan{
+		target:  t,
+		number:  nextComposeNumber(candidates),
+		source:  pickSource(candidates, opts.copySource),
+		targets: pickHosts(eligible, up, opts.spread),
+	}
+	p.victims = pickPlanVictims(deletable, int(down), opts, time.Now())
+	return p, nil
+}
+
+func checkStates(states []string) error {
+	if len(states) == 0 {
+		return fmt.Errorf("at least one candidate state is required")
+	}
+	for _, state := range states {
+		switch state {
+		case stateRunning, statePaused, stateExited:
+		default:
+			return fmt.Errorf("unknown state %q, expected %s, %s or %s", state, stateRunning, statePaused, stateExited)
+		}
+	}
+	return nil
+}
+
+// deletableCandidates returns the candidates created at least min age ago,
+// the only ones which can be deleted.
+func deletableCandidates(candidates []candidate, minAge time.Duration, now time.Time) []candidate {
+	if minAge <= 0 {
+		return candidates
+	}
+	deletable := []candidate{}
+	for _, c := range candidates {
+		if now.Sub(time.Unix(c.Created, 0)) >= minAge {
+			deletable = append(deletable, c)
+		}
+	}
+	return deletable
+}
+
+// job runs a cycle with the ratio, or with the ratio of the options, as
+// scheduled, when nil.
+func (r *runner) job(ratio *RatioValue) error {
+	if r.orchestrator != nil {
+		return r.orchestratorJob(ratio)
+	}
+	hosts, opts := r.hosts, r.opts
+	if len(opts.targets) == 0 {
+		r.log.Debug("no target")
+		return nil
+	}
+	target := pickTarget(opts.targets)
+	r.log.WithField("target", target.String()).Debug("cycle target")
+	candidates, err := r.listCandidates(target)
+	if err != nil {
+		return err
+	}
+	if r.drift != nil {
+		r.drift.observe(r.log, target, len(candidates))
+	}
+	// the lease is taken before anything is removed.
+	if r.leases != nil {
+		image := leaseImage(target, candidates)
+		if image == "" {
+			r.log.WithField("target", target.String()).Debug("no container to take the lease with")
+			return nil
+		}
+		if err := r.leases.acquire(hosts, target, image, opts); err != nil {
+			return err
+		}
+	}
+	if opts.gcAge > 0 && !locked(opts) {
+		if err := collectGarbage(hosts, target, opts.gcAge, &r.budget); err != nil {
+			return err
+		}
+		// collected containers may have been candidates.
+		if r.candidates != nil {
+			r.candidates.invalidate()
+		}
+		if candidates, err = r.listCandidates(target); err != nil {
+			return err
+		}
+		if r.drift != nil {
+			r.drift.expected[target.String()] = len(candidates)
+		}
+	}
+	if r.externals != nil {
+		candidates = r.externals.filter(r.log, candidates)
+	}
+	registry.set(metricCandidates, float64(len(candidates)), label{"target", target.String()})
+	if len(candidates) == 0 {
+		return nil
+	}
+	eligible, err := eligibleHosts(hosts, opts.constraints)
+	if err != nil {
+		return err
+	}
+	if len(eligible) == 0 {
+		r.log.Warn("no host satisfies placement constraints, no copy created")
+	}
+	now := time.Now()
+	cycleRatio := rampRatio(r.ratioAt(ratio, now), now.Sub(r.started), opts.ramp)
+	if r.coordinator != nil {
+		if cycleRatio, err = r.coordinator.divide(target, len(candidates), cycleRatio); err != nil {
+			return err
+		}
+	} else {
+		cycleRatio = cycleRatio.resolve(len(candidates))
+	}
+	r.log.WithField("ratio", cycleRatio.String()).Debug("cycle ratio")
+	p, err := makePlan(target, candidates, eligible, cycleRatio, opts)
+	if err != nil {
+		return err
+	}
+	return r.runPlan(p)
+}
+
+// runPlan confirms and runs the plan, rolling it back when atomic.
+func (r *runner) runPlan(p plan) error {
+	opts := r.opts
+	var err error
+	if opts.skipExec {
+		if p.victims, err = skipBusyVictims(p.victims); err != nil {
+			return err
+		}
+	}
+	if opts.interactive {
+		if p, err = confirmPlan(p); err != nil {
+			return err
+		}
+	}
+	if opts.fromSnapshot && len(p.targets) > 0 {
+		if p.image, err = r.snapshotSource(p); err != nil {
+			return err
+		}
+	}
+	// the specs of the victims are saved to recreate them, when they are
+	// logged or the cycle is rolled back.
+	var specs map[string]copySpec
+	if r.txlog != nil || opts.atomic {
+		if specs, err = victimSpecs(p.victims); err != nil {
+			return err
+		}
+	}
+	copies, err := copyContainer(r.log, p, opts, &r.budget, r.pool, r.frozen)
+	removed := []candidate{}
+	if err == nil {
+		removed, err = deleteContainer(r.log, p.victims, r.stats.cycles, opts, &r.budget)
+	}
+	if err != nil && opts.atomic {
+		lost := rollback(r.log, copies, removed, specs, opts)
+		copies, removed = nil, lost
+		if len(lost) > 0 {
+			err = fmt.Errorf("cycle partially rolled back, %v victims lost: %w", len(lost), err)
+		} else {
+			err = fmt.Errorf("cycle rolled back: %w", err)
+		}
+	}
+	if r.candidates != nil {
+		r.candidates.drop(removed)
+	}
+	if r.drift != nil {
+		r.drift.expect(p.target, len(copies), len(removed))
+	}
+	if r.txlog != nil {
+		if err := r.txlog.recordCycle(r.stats.cycles, copies, removed, specs); err != nil {
+			r.log.WithError(err).Error("could not record cycle")
+		}
+	}
+	return err
+}
+
+// confirmPlan asks the operator for each action of the plan and drops the
+// refused ones.
+func confirmPlan(p plan) (plan, error) {
+	if len(p.targets) > 0 {
+		ok, err := confirm("create %v copies of container %s?", len(p.targets), shortID(p.source.ID))
+		if err != nil {
+			return plan{}, err
+		}
+		if !ok {
+			logrus.WithField("container", p.source.ID).Info("copies refused by operator")
+			p.targets = nil
+		}
+	}
+	if len(p.victims) > 0 {
+		ok, err := confirm("delete containers %s?", strings.Join(victimIDs(p), ","))
+		if err != nil {
+			return plan{}, err
+		}
+		if !ok {
+			logrus.Info("deletions refused by operator")
+			p.victims = nil
+		}
+	}
+	return p, nil
+}
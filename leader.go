@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// renewScript extends the leader key only when it is still held by the
+// instance, and releaseScript deletes it on the same condition.
+const (
+	renewScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// elector elects one leader among redundant bubbles with a redis key
+// expiring after the ttl: the leader renews it, the others take it over
+// once it expires. Only the leader runs cycles.
+type elector struct {
+	redis  *redisClient
+	key    string
+	id     string
+	ttl    time.Duration
+	leader bool
+}
+
+func newElector(opts *options) *elector {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &elector{
+		redis: newRedisClient(opts.redisAddr, opts.redisPassword),
+		key:   opts.leaderKey,
+		id:    hostname + ":" + strconv.Itoa(os.Getpid()) + ":" + hex.EncodeToString(suffix),
+		ttl:   opts.leaderTTL,
+	}
+}
+
+// campaign renews the leadership or tries to take it, and tells if the
+// instance leads. Leadership is lost when the store can not be reached.
+func (e *elector) campaign() bool {
+	ttl := strconv.FormatInt(e.ttl.Milliseconds(), 10)
+	var leader bool
+	if e.leader {
+		reply, err := e.redis.do("EVAL", renewScript, "1", e.key, e.id, ttl)
+		if err != nil {
+			logrus.WithError(err).Error("could not renew leadership")
+		}
+		leader = err == nil && reply == int64(1)
+	} else {
+		reply, err := e.redis.do("SET", e.key, e.id, "NX", "PX", ttl)
+		if err != nil && err != errRedisNil {
+			logrus.WithError(err).Error("could not campaign for leadership")
+		}
+		leader = err == nil && reply == "OK"
+	}
+	if leader != e.leader {
+		if leader {
+			logrus.WithField("id", e.id).Info("elected leader")
+		} else {
+			logrus.WithField("id", e.id).Warn("leadership lost, standing by")
+		}
+		e.leader = leader
+	}
+	if leader {
+		registry.set(metricLeader, 1)
+	} else {
+		registry.set(metricLeader, 0)
+	}
+	return leader
+}
+
+// resign releases the leadership for a standby to take over immediately.
+func (e *elector) resign() {
+	if e.leader {
+		if _, err := e.redis.do("EVAL", releaseScript, "1", e.key, e.id); err != nil {
+			logrus.WithError(err).Error("could not release leadership")
+		}
+		e.leader = false
+	}
+	e.redis.Close()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	leaseLabel      = "bubble.lease"
+	leaseOwnerLabel = "bubble.lease.owner"
+)
+
+// leases are the leases held by this bubble, one per host and target. A
+// lease is a never started container whose name is derived from the target,
+// the daemon refusing a second container with the same name, so that two
+// bubbles never churn the same target on the same host.
+type leases struct {
+	owner string
+	held  map[string]leaseHolding
+}
+
+type leaseHolding struct {
+	host *host
+	id   string
+}
+
+func newLeases() *leases {
+	hostname, _ := os.Hostname()
+	return &leases{owner: hostname + ":" + strconv.Itoa(os.Getpid()), held: map[string]leaseHolding{}}
+}
+
+func leaseName(t target) string {
+	sum := sha256.Sum256([]byte(t.String()))
+	return "bubble-lease-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// leaseImage is the image lease containers of the target are created from,
+// empty when there is none to use.
+func leaseImage(t target, candidates []candidate) string {
+	if t.image != "" {
+		return t.image
+	}
+	if len(candidates) > 0 {
+		return candidates[0].Image
+	}
+	return ""
+}
+
+// acquire takes the lease of the target on every host, image being the one
+// of the lease containers, pulled on the hosts which do not have it.
+func (l *leases) acquire(hosts []*host, t target, image string, opts *options) error {
+	for _, h := range hosts {
+		key := h.name + " " + t.String()
+		if _, ok := l.held[key]; ok {
+			continue
+		}
+		id, err := l.acquireOn(h, t, image, opts, true)
+		if err != nil {
+			return err
+		}
+		l.held[key] = leaseHolding{host: h, id: id}
+		logrus.WithField("host", h.name).WithField("target", t.String()).Debug("lease acquired")
+	}
+	return nil
+}
+
+func (l *leases) acquireOn(h *host, t target, image string, opts *options, retry bool) (string, error) {
+	name := leaseName(t)
+	config := &ac.Config{
+		Image:  image,
+		Labels: map[string]string{leaseLabel: t.String(), leaseOwnerLabel: l.owner},
+	}
+	created, err := h.client.ContainerCreate(context.Background(), config, nil, nil, nil, name)
+	if errdefs.IsNotFound(err) {
+		// the host does not have the image yet, it must not be churned
+		// without lease.
+		if err := pullImage(h, image, "", opts); err != nil {
+			return "", fmt.Errorf("could not take lease of %s on host %s: %w", t, h.name, err)
+		}
+		created, err = h.client.ContainerCreate(context.Background(), config, nil, nil, nil, name)
+	}
+	if err == nil {
+		return created.ID, nil
+	}
+	if !errdefs.IsConflict(err) {
+		return "", fmt.Errorf("could not create lease of %s on host %s: %w", t, h.name, err)
+	}
+	infos, err := h.client.ContainerInspect(context.Background(), name)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect lease of %s on host %s: %w", t, h.name, err)
+	}
+	owner := infos.Config.Labels[leaseOwnerLabel]
+	if owner == l.owner {
+		return infos.ID, nil
+	}
+	if retry && l.stale(owner) {
+		logrus.WithField("host", h.name).WithField("owner", owner).Warn("remove stale lease")
+		if err := h.client.ContainerRemove(context.Background(), infos.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return "", fmt.Errorf("could not remove stale lease of %s on host %s: %w", t, h.name, err)
+		}
+		return l.acquireOn(h, t, image, opts, false)
+	}
+	return "", fmt.Errorf("%s is already churned on host %s by bubble %s, remove container %s if it is not running anymore", t, h.name, owner, name)
+}
+
+// stale tells if the owner of a lease is a bubble of this machine which is
+// not running anymore.
+func (l *leases) stale(owner string) bool {
+	i := strings.LastIndex(owner, ":")
+	hostname, _ := os.Hostname()
+	if i < 0 || owner[:i] != hostname {
+		return false
+	}
+	pid, err := strconv.Atoi(owner[i+1:])
+	return err == nil && !running(pid)
+}
+
+// release removes the lease containers.
+func (l *leases) release() {
+	for key, holding := range l.held {
+		if err := holding.host.client.ContainerRemove(context.Background(), holding.id, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logrus.WithError(err).WithField("host", holding.host.name).Error("could not release lease")
+		}
+		delete(l.held, key)
+	}
+}
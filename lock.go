@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// locked tells if the lock file exists. Any process, or an operator, holds
+// the lock by creating the file and releases it by removing it, while it is
+// held bubble performs no destructive action.
+func locked(opts *options) bool {
+	if opts.lock == "" {
+		return false
+	}
+	_, err := os.Stat(opts.lock)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).WithField("lock", opts.lock).Warn("could not check lock, considered held")
+		return true
+	}
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log modes.
+const (
+	logModeFull    = "full"
+	logModeSummary = "summary"
+)
+
+func checkLogMode(mode string) error {
+	if mode != logModeFull && mode != logModeSummary {
+		return fmt.Errorf("unknown log mode %q, expected %s or %s", mode, logModeFull, logModeSummary)
+	}
+	return nil
+}
+
+// summaryLogger is the logger of the cycles in summary mode: the standard
+// one, without the info lines logged for every container.
+func summaryLogger() *logrus.Logger {
+	std := logrus.StandardLogger()
+	level := std.GetLevel()
+	if level > logrus.WarnLevel && level <= logrus.InfoLevel {
+		level = logrus.WarnLevel
+	}
+	return &logrus.Logger{Out: std.Out, Hooks: std.Hooks, Formatter: std.Formatter, ReportCaller: std.ReportCaller, Level: level, ExitFunc: std.ExitFunc}
+}
+
+// cycleSummary logs one line per cycle with the counts of what it did,
+// replacing the lines of every container in summary mode.
+type cycleSummary struct {
+	created  int
+	removed  int
+	replaced int
+	failed   int
+}
+
+func (s *cycleSummary) handle(e busEvent) {
+	switch e.Kind {
+	case eventContainerCreated:
+		s.created++
+	case eventContainerRemoved:
+		s.removed++
+	case eventContainerReplaced:
+		s.replaced++
+	case eventError:
+		s.failed++
+	case eventCycleFinished:
+		logger := logrus.WithField("cycle_id", e.CycleID).
+			WithField("cycle", e.Cycle).
+			WithField("created", s.created).
+			WithField("removed", s.removed).
+			WithField("replaced", s.replaced).
+			WithField("failed", s.failed).
+			WithField("duration", e.Duration.Round(time.Millisecond))
+		if e.Err != nil {
+			logger = logger.WithError(e.Err)
+		}
+		logger.Info("cycle summary")
+		*s = cycleSummary{}
+	}
+}
@@ -1,183 +1,185 @@
 package main
 
 import (
-	"context"
 	"errors"
-	"fmt"
 	"math/rand"
+	"net"
 	"os"
-	"os/signal"
-	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	ac "github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 )
 
-func copyContainer(client *client.Client, container types.Container, n uint64) error {
-	infos, err := client.ContainerInspect(context.Background(), container.ID)
-	if err != nil {
-		return fmt.Errorf("could not inspect container id %s: %w", container.ID, err)
-	}
-	containerConfig := infos.Config
-	hostConfig := infos.ContainerJSONBase.HostConfig
-	networkConfig := &network.NetworkingConfig{
-		EndpointsConfig: container.NetworkSettings.Networks,
-	}
-	for i := uint64(0); i < n; i++ {
-		createdBody, err := client.ContainerCreate(
-			context.Background(),
-			containerConfig,
-			hostConfig,
-			networkConfig,
-			nil,
-			"",
-		)
-		if err != nil {
-			return fmt.Errorf("could not create container: %w", err)
-		}
-		for _, warning := range createdBody.Warnings {
-			logrus.Warn(warning)
+func main() {
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	known := false
+	for _, c := range commands {
+		known = known || c == command
+	}
+	if !known {
+		logrus.Errorf("unknown command %q, expected one of %s", command, strings.Join(commands, ", "))
+		os.Exit(exitCodeConfig)
+	}
+	if command == "completion" {
+		if len(args) != 1 {
+			logrus.Error("completion expects a shell: bash, zsh or fish")
+			os.Exit(exitCodeConfig)
 		}
-		logrus.WithField("container", createdBody.ID).Info("create container")
-		if err := client.ContainerStart(context.Background(), createdBody.ID, types.ContainerStartOptions{}); err != nil {
-			return fmt.Errorf("could not start container id %s: %w", createdBody.ID, err)
+		if err := completion(os.Stdout, args[0]); err != nil {
+			logrus.WithError(err).Error("could not generate completion")
+			os.Exit(exitCodeConfig)
 		}
-		logrus.WithField("container", createdBody.ID).Info("start container")
+		return
 	}
-	return nil
-}
 
-func deleteContainer(client *client.Client, candidates []types.Container, n uint64) error {
-	if int(n) > len(candidates) {
-		return fmt.Errorf("can not delete %v containers when exists only %v", n, len(candidates))
+	opts, fs, err := parseOptions(command, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	for i := uint64(0); i < n; i++ {
-		container := candidates[rand.Intn(len(candidates))]
-		if err := client.ContainerStop(context.Background(), container.ID, nil); err != nil {
-			return fmt.Errorf("could not stop container id: %s: %w", container.ID, err)
-		}
-		logrus.WithField("container", container.ID).Info("stop container")
-		readyCh, _ := client.ContainerWait(context.Background(), container.ID, ac.WaitConditionNotRunning)
-		<-readyCh
-		if err := client.ContainerRemove(context.Background(), container.ID, types.ContainerRemoveOptions{}); err != nil {
-			return fmt.Errorf("could not remove container id  %s: %w", container.ID, err)
-
-		}
-		logrus.WithField("container", container.ID).Info("remove container")
-	}
-	return nil
-}
-
-func job(client *client.Client, image string, ratio RatioValue) error {
-	containers, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
 	if err != nil {
-		return fmt.Errorf("could not get the list of containers: %w", err)
+		logrus.WithError(err).Error("could not parse options")
+		os.Exit(exitCodeConfig)
 	}
-	candidates := []types.Container{}
-	for _, container := range containers {
-		if container.Image == image {
-			candidates = append(candidates, container)
+	if command == "dashboard" {
+		if err := writeDashboard(os.Stdout, opts.dashboard); err != nil {
+			logrus.WithError(err).Error("could not generate dashboard")
+			os.Exit(exitCodeConfig)
 		}
+		return
 	}
-	for _, candidate := range candidates {
-		logrus.WithField("container", candidate.ID).WithField("image", candidate.Image).Debug("found container")
-	}
-	if len(candidates) == 0 {
-		return nil
-	}
-	rand.Seed(time.Now().Unix())
-	containertoCopy := candidates[rand.Intn(len(candidates))]
-	if err := copyContainer(client, containertoCopy, ratio.Up); err != nil {
-		return err
+	if command == "self-update" {
+		if err := selfUpdate(os.Stdout, opts); err != nil {
+			logrus.WithError(err).Error("self update failed")
+			os.Exit(exitCodeFailure)
+		}
+		return
 	}
-	if err := deleteContainer(client, candidates, ratio.Down); err != nil {
-		return err
+	if command == "version" {
+		hosts, err := newHosts(opts)
+		if err != nil {
+			logrus.WithError(err).Error("could not start docker client")
+			os.Exit(exitCodeConfig)
+		}
+		printVersion(os.Stdout, hosts)
+		closeHosts(hosts)
+		return
 	}
-	return nil
-}
-
-type RatioValue struct {
-	Up   uint64
-	Down uint64
-}
-
-func (r *RatioValue) String() string {
-	if r.Up == 0 || r.Down == 0 {
-		return "1:1"
+	if err := opts.check(); err != nil {
+		logrus.WithError(err).Error("could not start application")
+		fs.Usage()
+		os.Exit(exitCodeConfig)
 	}
-	return fmt.Sprintf("%v:%v", r.Up, r.Down)
-}
+	redaction = opts.redactor
+	logrus.AddHook(redaction)
 
-func (r *RatioValue) Set(s string) error {
-	vars := strings.Split(s, ":")
-	if len(vars) != 2 {
-		return errors.New("wrong format")
-	}
-	up, err := strconv.ParseUint(vars[0], 10, 8)
-	if err != nil {
-		return err
-	}
-	down, err := strconv.ParseUint(vars[1], 10, 8)
-	if err != nil {
-		return err
+	if opts.daemon && command == "run" {
+		parent, err := daemonize(opts)
+		if err != nil {
+			logrus.WithError(err).Error("could not daemonize")
+			os.Exit(exitCodeFailure)
+		}
+		if parent {
+			return
+		}
 	}
-	r.Up = up
-	r.Down = down
-	return nil
-}
-
-func (r *RatioValue) Type() string {
-	return "ratio"
-}
-
-func (r RatioValue) isZero() bool {
-	return r.Up == 0 || r.Down == 0
-}
-
-func main() {
-
-	var ratio RatioValue
-
-	image := flag.StringP("image", "i", "", "containers base on this image will be delete and start again.")
-	freq := flag.DurationP("freq", "f", time.Minute, "frequency")
-	flag.VarP(&ratio, "ratio", "r", "ratio: x creation: y deletion. eg 1:2, 2:1, 1:1")
-	flag.Parse()
-
-	if ratio.isZero() {
-		ratio = RatioValue{1, 1}
+	if opts.logFile != "" {
+		f, err := os.OpenFile(opts.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			logrus.WithError(err).Error("could not open log file")
+			os.Exit(exitCodeFailure)
+		}
+		defer f.Close()
+		logrus.SetOutput(f)
 	}
 
-	sig := make(chan os.Signal)
-	signal.Notify(sig, syscall.SIGSTOP, syscall.SIGTERM)
+	rand.Seed(time.Now().UnixNano())
 
-	if *image == "" {
-		logrus.Error("could not start application, image argument is empty.")
-		flag.Usage()
-		os.Exit(1)
+	if command == "simulate" {
+		if err := simulate(opts); err != nil {
+			logrus.WithError(err).Error("simulation failed")
+			os.Exit(exitCode(err))
+		}
+		return
 	}
 
-	client, err := client.NewEnvClient()
+	hosts, err := newHosts(opts)
 	if err != nil {
 		logrus.WithError(err).Error("could not start docker client")
-		os.Exit(1)
+		os.Exit(exitCodeConfig)
 	}
-	defer client.Close()
-	for {
-		select {
-		case <-time.After(*freq):
-			if err := job(client, *image, ratio); err != nil {
-				logrus.WithError(err).Error("job failed")
+	defer closeHosts(hosts)
+
+	switch command {
+	case "validate":
+		if err := validate(hosts, opts); err != nil {
+			logrus.WithError(err).Error("validation failed")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+	case "bench":
+		if err := bench(hosts, opts); err != nil {
+			logrus.WithError(err).Error("benchmark failed")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+	case "scale-up":
+		if err := scaleUp(hosts, opts); err != nil {
+			logrus.WithError(err).Error("scale up failed")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+	case "selftest":
+		if err := selftest(hosts, opts); err != nil {
+			logrus.WithError(err).Error("selftest failed")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+	case "undo":
+		if err := undo(hosts, opts); err != nil {
+			logrus.WithError(err).Error("undo failed")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+	default:
+		if l := activationListener(); l != nil {
+			go serve(l, opts)
+		} else if opts.listen != "" {
+			l, err := net.Listen("tcp", opts.listen)
+			if err != nil {
+				logrus.WithError(err).Error("could not listen")
+				closeHosts(hosts)
+				os.Exit(exitCodeFailure)
 			}
-		case <-sig:
-			logrus.Info("received stop signal")
-			return
+			go serve(l, opts)
+		}
+		if opts.output == outputJSONL {
+			startActions(os.Stdout)
+		}
+		r, err := newRunner(hosts, opts)
+		if err != nil {
+			logrus.WithError(err).Error("could not start application")
+			closeHosts(hosts)
+			os.Exit(exitCode(err))
+		}
+		if opts.pidFile != "" {
+			if err := writePidFile(opts.pidFile); err != nil {
+				logrus.WithError(err).Error("could not start application")
+				closeHosts(hosts)
+				os.Exit(exitCodeFailure)
+			}
+		}
+		ok := r.run()
+		if opts.pidFile != "" {
+			os.Remove(opts.pidFile)
+		}
+		if code := r.exitCode(ok); code != exitCodeOK {
+			closeHosts(hosts)
+			os.Exit(code)
 		}
 	}
 }
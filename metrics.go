@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	counterKind   = "counter"
+	gaugeKind     = "gauge"
+	histogramKind = "histogram"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the
+// duration histograms.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metricDesc describes a metric exposed by bubble, with the names of the
+// labels of its series.
+type metricDesc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// Metric names.
+const (
+	metricCycles        = "bubble_cycles_total"
+	metricCyclesFailed  = "bubble_cycles_failed_total"
+	metricCreated       = "bubble_containers_created_total"
+	metricRemoved       = "bubble_containers_removed_total"
+	metricOpsFailed     = "bubble_operations_failed_total"
+	metricCandidates    = "bubble_candidates"
+	metricProbesFailed  = "bubble_probes_failed_total"
+	metricProbesSuccess = "bubble_probes_succeeded_total"
+	metricLeader        = "bubble_leader"
+	metricReplaced      = "bubble_unhealthy_replaced_total"
+	metricExits         = "bubble_container_exits_total"
+	metricOOMKilled     = "bubble_containers_oom_killed_total"
+	metricDrift         = "bubble_fleet_drift"
+	metricStepDuration  = "bubble_cycle_step_duration_seconds"
+)
+
+// metricDescs is the registry of every metric bubble exposes.
+var metricDescs = []metricDesc{
+	{metricCycles, "Number of churn cycles run.", counterKind, nil},
+	{metricCyclesFailed, "Number of churn cycles which failed.", counterKind, nil},
+	{metricCreated, "Number of copies created and started.", counterKind, []string{"host"}},
+	{metricRemoved, "Number of containers stopped and removed.", counterKind, []string{"host"}},
+	{metricOpsFailed, "Number of create and remove operations which failed.", counterKind, nil},
+	{metricCandidates, "Number of containers matching the target of the last cycle.", gaugeKind, []string{"target"}},
+	{metricProbesFailed, "Number of probes which failed.", counterKind, nil},
+	{metricProbesSuccess, "Number of probes which succeeded.", counterKind, nil},
+	{metricLeader, "Whether this bubble is the leader running the cycles.", gaugeKind, nil},
+	{metricReplaced, "Number of unhealthy containers replaced.", counterKind, []string{"host"}},
+	{metricExits, "Number of containers stopped or found dead, by exit code.", counterKind, []string{"code", "cause"}},
+	{metricOOMKilled, "Number of containers stopped or found dead which were oom killed.", counterKind, []string{"host"}},
+	{metricDrift, "Number of containers of the target found by the last cycle minus the number expected.", gaugeKind, []string{"target"}},
+	{metricStepDuration, "Duration of the docker api calls of the cycles, by step.", histogramKind, []string{"step"}},
+}
+
+// label is a metric label.
+type label struct {
+	name  string
+	value string
+}
+
+// series identifies a metric with its labels, written like in the
+// prometheus text format, e.g. bubble_candidates{target="redis"}.
+func series(name string, labels ...label) string {
+	if len(labels) == 0 {
+		return name
+	}
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		parts = append(parts, fmt.Sprintf("%s=%q", l.name, l.value))
+	}
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// seriesName returns the metric name of a series.
+func seriesName(s string) string {
+	if i := strings.Index(s, "{"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// metrics holds the current value of every series.
+type metrics struct {
+	mu         sync.Mutex
+	values     map[string]float64
+	histograms map[string]*histogram
+}
+
+// histogram counts the observations of a series in the duration buckets.
+type histogram struct {
+	name   string
+	labels []label
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var registry = &metrics{values: map[string]float64{}, histograms: map[string]*histogram{}}
+
+func (m *metrics) add(name string, v float64, labels ...label) {
+	m.mu.Lock()
+	m.values[series(name, labels...)] += v
+	m.mu.Unlock()
+}
+
+func (m *metrics) inc(name string, labels ...label) {
+	m.add(name, 1, labels...)
+}
+
+func (m *metrics) set(name string, v float64, labels ...label) {
+	m.mu.Lock()
+	m.values[series(name, labels...)] = v
+	m.mu.Unlock()
+}
+
+func (m *metrics) observe(name string, v float64, labels ...label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := series(name, labels...)
+	h, ok := m.histograms[s]
+	if !ok {
+		h = &histogram{name: name, labels: labels, counts: make([]uint64, len(durationBuckets))}
+		m.histograms[s] = h
+	}
+	for i, bound := range durationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (m *metrics) snapshot() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make(map[string]float64, len(m.values))
+	for s, v := range m.values {
+		values[s] = v
+	}
+	return values
+}
+
+// writeText writes the metrics in the prometheus text format.
+func (m *metrics) writeText(w io.Writer) error {
+	values := m.snapshot()
+	all := make([]string, 0, len(values))
+	for s := range values {
+		all = append(all, s)
+	}
+	sort.Strings(all)
+	for _, desc := range metricDescs {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", desc.name, desc.help, desc.name, desc.kind); err != nil {
+			return err
+		}
+		if desc.kind == histogramKind {
+			if err := m.writeHistograms(w, desc.name); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, s := range all {
+			if seriesName(s) != desc.name {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s %v\n", s, values[s]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeHistograms writes the buckets, sum and count of the series of the
+// histogram, buckets in increasing order as the format requires.
+func (m *metrics) writeHistograms(w io.Writer, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := []string{}
+	for s, h := range m.histograms {
+		if h.name == name {
+			all = append(all, s)
+		}
+	}
+	sort.Strings(all)
+	for _, s := range all {
+		h := m.histograms[s]
+		for i, bound := range durationBuckets {
+			le := append(h.labels[:len(h.labels):len(h.labels)], label{"le", fmt.Sprint(bound)})
+			if _, err := fmt.Fprintf(w, "%s %v\n", series(name+"_bucket", le...), h.counts[i]); err != nil {
+				return err
+			}
+		}
+		inf := append(h.labels[:len(h.labels):len(h.labels)], label{"le", "+Inf"})
+		if _, err := fmt.Fprintf(w, "%s %v\n%s %v\n%s %v\n", series(name+"_bucket", inf...), h.count, series(name+"_sum", h.labels...), h.sum, series(name+"_count", h.labels...), h.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := registry.writeText(w); err != nil {
+		logrus.WithError(err).Debug("could not write metrics")
+	}
+}
+
+// serve exposes the metrics, and the control endpoints when the api is
+// authenticated, on the listener until the process exits.
+func serve(l net.Listener, opts *options) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", require(permissionRead, opts, metricsHandler))
+	if len(opts.controlTokens) > 0 || opts.tlsClientCA != "" {
+		mux.HandleFunc("/pause", require(permissionControl, opts, pauseHandler(true)))
+		mux.HandleFunc("/resume", require(permissionControl, opts, pauseHandler(false)))
+	}
+	if opts.debugEndpoints {
+		handleDebug(mux, opts)
+	}
+	logrus.WithField("addr", l.Addr().String()).Info("listen")
+	server := &http.Server{Handler: mux}
+	var err error
+	if opts.tlsCert != "" {
+		if server.TLSConfig, err = tlsConfig(opts); err == nil {
+			err = server.ServeTLS(l, opts.tlsCert, opts.tlsKey)
+		}
+	} else {
+		err = server.Serve(l)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("could not listen")
+	}
+}
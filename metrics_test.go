@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	m := &metrics{values: map[string]float64{}, histograms: map[string]*histogram{}}
+	m.observe(metricStepDuration, 0.02, label{"step", stepCreate})
+	m.observe(metricStepDuration, 3, label{"step", stepCreate})
+	var out bytes.Buffer
+	if err := m.writeHistograms(&out, metricStepDuration); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got, want := len(lines), len(durationBuckets)+3; got != want {
+		t.Fatalf("got %v lines, want %v:\n%s", got, want, out.String())
+	}
+	for _, want := range []string{
+		`bubble_cycle_step_duration_seconds_bucket{step="create",le="0.01"} 0`,
+		`bubble_cycle_step_duration_seconds_bucket{step="create",le="0.025"} 1`,
+		`bubble_cycle_step_duration_seconds_bucket{step="create",le="5"} 2`,
+		`bubble_cycle_step_duration_seconds_bucket{step="create",le="+Inf"} 2`,
+		`bubble_cycle_step_duration_seconds_sum{step="create"} 3.02`,
+		`bubble_cycle_step_duration_seconds_count{step="create"} 2`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// mockClient is a fake daemon for the container operations of the cycles:
+// each one calls its func when set, and succeeds doing nothing otherwise.
+// The other operations of the docker client panic, through the nil
+// dockerClient it embeds. Calls are recorded in order.
+type mockClient struct {
+	dockerClient
+	ListFunc    func(options types.ContainerListOptions) ([]types.Container, error)
+	InspectFunc func(id string) (types.ContainerJSON, error)
+	CreateFunc  func(config *ac.Config, hostConfig *ac.HostConfig, name string) (ac.ContainerCreateCreatedBody, error)
+	StartFunc   func(id string) error
+	StopFunc    func(id string) error
+	KillFunc    func(id, signal string) error
+	UnpauseFunc func(id string) error
+	WaitFunc    func(id string, condition ac.WaitCondition) (ac.ContainerWaitOKBody, error)
+	RemoveFunc  func(id string, options types.ContainerRemoveOptions) error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *mockClient) call(op, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, op+" "+id)
+}
+
+// Calls returns the recorded calls, e.g. "start abc".
+func (m *mockClient) Calls() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return strings.Join(m.calls, ", ")
+}
+
+func (m *mockClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	m.call("list", "")
+	if m.ListFunc == nil {
+		return nil, nil
+	}
+	return m.ListFunc(options)
+}
+
+func (m *mockClient) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	m.call("inspect", id)
+	if m.InspectFunc == nil {
+		return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: id, State: &types.ContainerState{}}}, nil
+	}
+	return m.InspectFunc(id)
+}
+
+func (m *mockClient) ContainerCreate(ctx context.Context, config *ac.Config, hostConfig *ac.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, name string) (ac.ContainerCreateCreatedBody, error) {
+	m.call("create", name)
+	if m.CreateFunc == nil {
+		return ac.ContainerCreateCreatedBody{ID: name}, nil
+	}
+	return m.CreateFunc(config, hostConfig, name)
+}
+
+func (m *mockClient) ContainerStart(ctx context.Context, id string, options types.ContainerStartOptions) error {
+	m.call("start", id)
+	if m.StartFunc == nil {
+		return nil
+	}
+	return m.StartFunc(id)
+}
+
+func (m *mockClient) ContainerStop(ctx context.Context, id string, timeout *time.Duration) error {
+	m.call("stop", id)
+	if m.StopFunc == nil {
+		return nil
+	}
+	return m.StopFunc(id)
+}
+
+func (m *mockClient) ContainerKill(ctx context.Context, id, signal string) error {
+	m.call("kill "+signal, id)
+	if m.KillFunc == nil {
+		return nil
+	}
+	return m.KillFunc(id, signal)
+}
+
+func (m *mockClient) ContainerUnpause(ctx context.Context, id string) error {
+	m.call("unpause", id)
+	if m.UnpauseFunc == nil {
+		return nil
+	}
+	return m.UnpauseFunc(id)
+}
+
+func (m *mockClient) ContainerWait(ctx context.Context, id string, condition ac.WaitCondition) (<-chan ac.ContainerWaitOKBody, <-chan error) {
+	m.call("wait", id)
+	status, errs := make(chan ac.ContainerWaitOKBody, 1), make(chan error, 1)
+	if m.WaitFunc == nil {
+		status <- ac.ContainerWaitOKBody{}
+		return status, errs
+	}
+	body, err := m.WaitFunc(id, condition)
+	if err != nil {
+		errs <- err
+	} else {
+		status <- body
+	}
+	return status, errs
+}
+
+func (m *mockClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	m.call("remove", id)
+	if m.RemoveFunc == nil {
+		return nil
+	}
+	return m.RemoveFunc(id, options)
+}
+
+// mockHost returns a linux host whose daemon is the mock.
+func mockHost(m *mockClient) *host {
+	return &host{name: "mock", client: m, ostype: "linux"}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+)
+
+// randomSuffix returns a short random hex string used to tell copies apart.
+func randomSuffix() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// prepareNetworks keeps only the configuration part of the source endpoints,
+// without static addresses which would collide, static IPv6 addresses being
+// regenerated in their subnet, and moves all endpoints but
+// the primary one to ExtraEndpoints, since a container can only be created
+// attached to a single network. The aliases are suffixed unless kept, the
+// compose service alias always being kept so that copies of a service are
+// reached by its name.
+func prepareNetworks(spec *copySpec, sourceID, suffix, service string, opts *options) {
+	if spec.Config != nil {
+		spec.Config.MacAddress = ""
+	}
+	if spec.NetworkingConfig == nil || len(spec.NetworkingConfig.EndpointsConfig) == 0 {
+		return
+	}
+	names := make([]string, 0, len(spec.NetworkingConfig.EndpointsConfig))
+	for name := range spec.NetworkingConfig.EndpointsConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	primary := names[0]
+	if spec.HostConfig != nil {
+		if _, ok := spec.NetworkingConfig.EndpointsConfig[string(spec.HostConfig.NetworkMode)]; ok {
+			primary = string(spec.HostConfig.NetworkMode)
+		}
+	}
+
+	endpoints := spec.NetworkingConfig.EndpointsConfig
+	spec.NetworkingConfig.EndpointsConfig = map[string]*network.EndpointSettings{}
+	spec.ExtraEndpoints = nil
+	for _, name := range names {
+		source := endpoints[name]
+		endpoint := &network.EndpointSettings{
+			Links:      source.Links,
+			DriverOpts: source.DriverOpts,
+		}
+		for _, alias := range source.Aliases {
+			if alias == shortID(sourceID) {
+				continue
+			}
+			if !opts.keepAliases && alias != service {
+				alias += "-" + suffix
+			}
+			endpoint.Aliases = append(endpoint.Aliases, alias)
+		}
+		if address := copyIPv6(source, opts); address != "" {
+			endpoint.IPAMConfig = &network.EndpointIPAMConfig{IPv6Address: address}
+		}
+		if name == primary {
+			spec.NetworkingConfig.EndpointsConfig[name] = endpoint
+			continue
+		}
+		if spec.ExtraEndpoints == nil {
+			spec.ExtraEndpoints = map[string]*network.EndpointSettings{}
+		}
+		spec.ExtraEndpoints[name] = endpoint
+	}
+}
+
+// isolatedNetworkLabel holds, on a copy, the name of the network created
+// for it alone.
+const isolatedNetworkLabel = "bubble.network"
+
+// prepareIsolatedNetwork attaches the copy only to a network of its own,
+// created with the container and removed with it.
+func prepareIsolatedNetwork(spec *copySpec) error {
+	if spec.HostConfig == nil {
+		spec.HostConfig = &ac.HostConfig{}
+	}
+	if mode := spec.HostConfig.NetworkMode; mode.IsHost() || mode.IsContainer() || mode.IsNone() {
+		return fmt.Errorf("can not isolate a container with network mode %s", mode)
+	}
+	name := spec.Name + "-net"
+	spec.Config.Labels[isolatedNetworkLabel] = name
+	spec.HostConfig.NetworkMode = ac.NetworkMode(name)
+	spec.NetworkingConfig = &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{name: {}},
+	}
+	spec.ExtraEndpoints = nil
+	return nil
+}
+
+func createIsolatedNetwork(target *host, name string) error {
+	_, err := target.client.NetworkCreate(context.Background(), name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         map[string]string{managedLabel: "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create network %s on host %s: %w", name, target.name, err)
+	}
+	logrus.WithField("network", name).WithField("host", target.name).Info("create network")
+	return nil
+}
+
+func removeIsolatedNetwork(target *host, name string) error {
+	if err := target.client.NetworkRemove(context.Background(), name); err != nil {
+		return fmt.Errorf("could not remove network %s from host %s: %w", name, target.name, err)
+	}
+	logrus.WithField("network", name).WithField("host", target.name).Info("remove network")
+	return nil
+}
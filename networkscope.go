@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func checkNetworkScope(o *options) error {
+	if o.networkScope == "" {
+		return nil
+	}
+	if o.backend != backendDocker {
+		return errors.New("network scope requires the docker backend")
+	}
+	if o.isolatedNetwork {
+		return errors.New("--network-scope can not be used with --isolated-network")
+	}
+	return nil
+}
+
+// networkScoped restricts the targets to the containers attached to the
+// network, when not empty.
+func networkScoped(targets []target, name string) []target {
+	for i := range targets {
+		targets[i].network = name
+	}
+	return targets
+}
+
+// attached tells whether the container is attached to the network.
+func attached(container types.Container, name string) bool {
+	if container.NetworkSettings == nil {
+		return false
+	}
+	_, ok := container.NetworkSettings.Networks[name]
+	return ok
+}
+
+// prepareNetworkScope attaches the copy only to the network of the scope,
+// with the endpoint settings its source has there, so that copies do not
+// join the other environments their source is attached to.
+func prepareNetworkScope(spec *copySpec, name string) {
+	endpoint := &network.EndpointSettings{}
+	if spec.NetworkingConfig != nil && spec.NetworkingConfig.EndpointsConfig[name] != nil {
+		endpoint = spec.NetworkingConfig.EndpointsConfig[name]
+	} else if spec.ExtraEndpoints[name] != nil {
+		endpoint = spec.ExtraEndpoints[name]
+	}
+	if spec.HostConfig == nil {
+		spec.HostConfig = &ac.HostConfig{}
+	}
+	spec.HostConfig.NetworkMode = ac.NetworkMode(name)
+	spec.NetworkingConfig = &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{name: endpoint},
+	}
+	spec.ExtraEndpoints = nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestNetworkScope(t *testing.T) {
+	target := networkScoped([]target{{image: "redis"}}, "staging")[0]
+	if got, want := target.String(), "redis on staging"; got != want {
+		t.Errorf("got target %s, want %s", got, want)
+	}
+	for _, tc := range []struct {
+		networks []string
+		want     bool
+	}{
+		{[]string{"staging"}, true},
+		{[]string{"prod", "staging"}, true},
+		{[]string{"prod"}, false},
+		{nil, false},
+	} {
+		settings := &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{}}
+		for _, name := range tc.networks {
+			settings.Networks[name] = &network.EndpointSettings{}
+		}
+		if got := target.matches(types.Container{Image: "redis", NetworkSettings: settings}); got != tc.want {
+			t.Errorf("%v: got match %v, want %v", tc.networks, got, tc.want)
+		}
+	}
+}
+
+func TestPrepareNetworkScope(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec copySpec
+	}{
+		{"primary", copySpec{
+			HostConfig:       &ac.HostConfig{NetworkMode: "staging"},
+			NetworkingConfig: &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{"staging": {Aliases: []string{"cache"}}}},
+			ExtraEndpoints:   map[string]*network.EndpointSettings{"prod": {}},
+		}},
+		{"extra", copySpec{
+			HostConfig:       &ac.HostConfig{NetworkMode: "prod"},
+			NetworkingConfig: &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{"prod": {}}},
+			ExtraEndpoints:   map[string]*network.EndpointSettings{"staging": {Aliases: []string{"cache"}}},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := tc.spec
+			prepareNetworkScope(&spec, "staging")
+			if spec.HostConfig.NetworkMode != "staging" {
+				t.Errorf("got network mode %s, want staging", spec.HostConfig.NetworkMode)
+			}
+			endpoints := spec.NetworkingConfig.EndpointsConfig
+			if len(endpoints) != 1 || endpoints["staging"] == nil || len(endpoints["staging"].Aliases) != 1 {
+				t.Errorf("got endpoints %v, want the staging endpoint only", endpoints)
+			}
+			if spec.ExtraEndpoints != nil {
+				t.Errorf("got extra endpoints %v, want none", spec.ExtraEndpoints)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// nomad churns the allocations of a task group of a nomad job through the
+// nomad http api.
+type nomad struct {
+	addr      string
+	token     string
+	namespace string
+	job       string
+	group     string
+	client    *http.Client
+}
+
+func newNomad(opts *options) *nomad {
+	return &nomad{
+		addr:      strings.TrimSuffix(opts.nomadAddr, "/"),
+		token:     opts.nomadToken,
+		namespace: opts.nomadNamespace,
+		job:       opts.nomadJob,
+		group:     opts.nomadGroup,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func defaultNomadAddr() string {
+	if addr := os.Getenv("NOMAD_ADDR"); addr != "" {
+		return addr
+	}
+	return "http://127.0.0.1:4646"
+}
+
+func (n *nomad) String() string {
+	return n.job + "/" + n.group
+}
+
+// do sends the request to the nomad api and decodes its answer into out.
+func (n *nomad) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	u := n.addr + path
+	if n.namespace != "" {
+		u += "?namespace=" + url.QueryEscape(n.namespace)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if n.token != "" {
+		req.Header.Set("X-Nomad-Token", n.token)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call nomad %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("nomad %s %s answered %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode nomad %s %s answer: %w", method, path, err)
+	}
+	return nil
+}
+
+func (n *nomad) count() (int, error) {
+	var job struct {
+		TaskGroups []struct {
+			Name  string
+			Count int
+		}
+	}
+	if err := n.do(http.MethodGet, "/v1/job/"+url.PathEscape(n.job), nil, &job); err != nil {
+		return 0, err
+	}
+	for _, group := range job.TaskGroups {
+		if group.Name == n.group {
+			return group.Count, nil
+		}
+	}
+	return 0, fmt.Errorf("nomad job %s has no group %s", n.job, n.group)
+}
+
+func (n *nomad) units() ([]string, error) {
+	var allocations []struct {
+		ID            string
+		TaskGroup     string
+		ClientStatus  string
+		DesiredStatus string
+	}
+	if err := n.do(http.MethodGet, "/v1/job/"+url.PathEscape(n.job)+"/allocations", nil, &allocations); err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, alloc := range allocations {
+		if alloc.TaskGroup == n.group && alloc.ClientStatus == "running" && alloc.DesiredStatus == "run" {
+			ids = append(ids, alloc.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (n *nomad) scale(count int) error {
+	return n.do(http.MethodPost, "/v1/job/"+url.PathEscape(n.job)+"/scale", map[string]interface{}{
+		"Count":   count,
+		"Target":  map[string]string{"Group": n.group},
+		"Message": "scaled by bubble",
+	}, nil)
+}
+
+func (n *nomad) stop(id string) error {
+	return n.do(http.MethodPost, "/v1/allocation/"+url.PathEscape(id)+"/stop", nil, nil)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notifier is a notification target, told about the cycles, the failed
+// operations and the stop of bubble.
+type Notifier interface {
+	OnCycle(e busEvent) error
+	OnError(e busEvent) error
+	OnShutdown(s Shutdown) error
+}
+
+// Shutdown sums up a run when bubble stops.
+type Shutdown struct {
+	Cycles       int           `json:"cycles"`
+	FailedCycles int           `json:"failed_cycles"`
+	Uptime       time.Duration `json:"uptime_ns"`
+}
+
+// NotifierFactory returns the notifier of a --notify flag, given what follows
+// the name of the notifier, empty when there is nothing.
+type NotifierFactory func(arg string) (Notifier, error)
+
+var notifierFactories = map[string]NotifierFactory{}
+
+// RegisterNotifier makes a notifier available to --notify under its name.
+func RegisterNotifier(name string, factory NotifierFactory) {
+	notifierFactories[name] = factory
+}
+
+func notifierNames() []string {
+	names := make([]string, 0, len(notifierFactories))
+	for name := range notifierFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterNotifier("stdout", func(string) (Notifier, error) { return writerNotifier{w: os.Stdout}, nil })
+	RegisterNotifier("webhook", func(url string) (Notifier, error) {
+		if url == "" {
+			return nil, fmt.Errorf("webhook notifier requires an url, e.g. webhook=https://example.com/hook")
+		}
+		return webhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	})
+	RegisterNotifier("slack", func(url string) (Notifier, error) {
+		if url == "" {
+			return nil, fmt.Errorf("slack notifier requires an incoming webhook url, e.g. slack=https://hooks.slack.com/services/...")
+		}
+		return slackNotifier{webhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}}, nil
+	})
+}
+
+// parseNotifier parses a --notify flag, name[=argument].
+func parseNotifier(s string) (Notifier, error) {
+	name, arg := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, arg = s[:i], s[i+1:]
+	}
+	factory, ok := notifierFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier %q, expected one of %s", name, strings.Join(notifierNames(), ", "))
+	}
+	return factory(arg)
+}
+
+// notifications hand the bus events to the notifiers on a goroutine of
+// their own, so that a slow target does not hold the cycles.
+type notifications struct {
+	notifiers []Notifier
+	calls     chan func()
+	done      sync.WaitGroup
+	mu        sync.Mutex
+	closed    bool
+}
+
+func newNotifications(notifiers []Notifier) *notifications {
+	n := &notifications{notifiers: notifiers, calls: make(chan func(), 100)}
+	n.done.Add(1)
+	go func() {
+		defer n.done.Done()
+		for call := range n.calls {
+			call()
+		}
+	}()
+	return n
+}
+
+func (n *notifications) handle(e busEvent) {
+	switch e.Kind {
+	case eventCycleFinished:
+		n.notify(func(notifier Notifier) error { return notifier.OnCycle(e) })
+	case eventError, eventDrift, eventExternal:
+		n.notify(func(notifier Notifier) error { return notifier.OnError(e) })
+	}
+}
+
+func (n *notifications) notify(call func(Notifier) error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.calls <- func() {
+		for _, notifier := range n.notifiers {
+			if err := call(notifier); err != nil {
+				logrus.WithError(err).Warn("could not notify")
+			}
+		}
+	}:
+	default:
+		logrus.Warn("notifications are late, notification dropped")
+	}
+}
+
+// shutdown notifies the stop of bubble and waits for the notifications to be
+// sent.
+func (n *notifications) shutdown(s Shutdown) {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+	n.calls <- func() {
+		for _, notifier := range n.notifiers {
+			if err := notifier.OnShutdown(s); err != nil {
+				logrus.WithError(err).Warn("could not notify")
+			}
+		}
+	}
+	close(n.calls)
+	n.done.Wait()
+}
+
+func describeCycle(e busEvent) string {
+	if e.Err != nil {
+		return fmt.Sprintf("bubble cycle %d failed after %v: %v", e.Cycle, e.Duration.Round(time.Millisecond), e.Err)
+	}
+	return fmt.Sprintf("bubble cycle %d succeeded in %v", e.Cycle, e.Duration.Round(time.Millisecond))
+}
+
+func describeShutdown(s Shutdown) string {
+	return fmt.Sprintf("bubble stopped after %v, %d cycles, %d failed", s.Uptime.Round(time.Second), s.Cycles, s.FailedCycles)
+}
+
+// writerNotifier writes a line per notification.
+type writerNotifier struct {
+	w io.Writer
+}
+
+func (n writerNotifier) OnCycle(e busEvent) error {
+	_, err := fmt.Fprintln(n.w, describeCycle(e))
+	return err
+}
+
+func (n writerNotifier) OnError(e busEvent) error {
+	_, err := fmt.Fprintf(n.w, "bubble operation failed: %v\n", e.Err)
+	return err
+}
+
+func (n writerNotifier) OnShutdown(s Shutdown) error {
+	_, err := fmt.Fprintln(n.w, describeShutdown(s))
+	return err
+}
+
+// webhookNotifier posts a json object per notification to an url.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// notification is the body posted by the webhook notifier.
+type notification struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Cycle    int       `json:"cycle,omitempty"`
+	CycleID  string    `json:"cycle_id,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_seconds,omitempty"`
+	Shutdown *Shutdown `json:"shutdown,omitempty"`
+}
+
+func newNotification(event string, e busEvent) notification {
+	body := notification{Event: event, Time: e.Time, Cycle: e.Cycle, CycleID: e.CycleID, Duration: e.Duration.Seconds()}
+	if e.Err != nil {
+		body.Error = e.Err.Error()
+	}
+	return body
+}
+
+func (n webhookNotifier) OnCycle(e busEvent) error {
+	return n.post(newNotification("cycle", e))
+}
+
+func (n webhookNotifier) OnError(e busEvent) error {
+	return n.post(newNotification("error", e))
+}
+
+func (n webhookNotifier) OnShutdown(s Shutdown) error {
+	return n.post(notification{Event: "shutdown", Time: time.Now(), Shutdown: &s})
+}
+
+func (n webhookNotifier) post(body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not encode notification: %w", err)
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("could not post notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("could not post notification: status %s", resp.Status)
+	}
+	return nil
+}
+
+// slackNotifier posts to a slack incoming webhook, only the failures and the
+// stop of bubble, each cycle would flood the channel.
+type slackNotifier struct {
+	webhook webhookNotifier
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func (n slackNotifier) OnCycle(e busEvent) error {
+	if e.Err == nil {
+		return nil
+	}
+	return n.webhook.post(slackMessage{Text: describeCycle(e)})
+}
+
+func (n slackNotifier) OnError(e busEvent) error {
+	return n.webhook.post(slackMessage{Text: fmt.Sprintf("bubble operation failed: %v", e.Err)})
+}
+
+func (n slackNotifier) OnShutdown(s Shutdown) error {
+	return n.webhook.post(slackMessage{Text: describeShutdown(s)})
+}
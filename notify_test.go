@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNotifier(t *testing.T) {
+	for _, tc := range []struct {
+		flag string
+		ok   bool
+	}{
+		{"stdout", true},
+		{"webhook=http://localhost/hook", true},
+		{"slack=https://hooks.slack.com/services/x", true},
+		{"webhook", false},
+		{"slack=", false},
+		{"pager", false},
+	} {
+		_, err := parseNotifier(tc.flag)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: got error %v, want ok %v", tc.flag, err, tc.ok)
+		}
+	}
+}
+
+type recordingNotifier struct {
+	calls []string
+}
+
+func (n *recordingNotifier) OnCycle(e busEvent) error {
+	n.calls = append(n.calls, "cycle")
+	return nil
+}
+
+func (n *recordingNotifier) OnError(e busEvent) error {
+	n.calls = append(n.calls, "error")
+	return nil
+}
+
+func (n *recordingNotifier) OnShutdown(s Shutdown) error {
+	n.calls = append(n.calls, "shutdown")
+	return nil
+}
+
+func TestNotifications(t *testing.T) {
+	recorder := &recordingNotifier{}
+	n := newNotifications([]Notifier{recorder})
+	n.handle(busEvent{Kind: eventError, Err: errors.New("boom")})
+	n.handle(busEvent{Kind: eventContainerCreated})
+	n.handle(busEvent{Kind: eventCycleFinished, Cycle: 1})
+	n.shutdown(Shutdown{Cycles: 1})
+	n.handle(busEvent{Kind: eventCycleFinished, Cycle: 2})
+	want := []string{"error", "cycle", "shutdown"}
+	if len(recorder.calls) != len(want) {
+		t.Fatalf("got calls %v, want %v", recorder.calls, want)
+	}
+	for i := range want {
+		if recorder.calls[i] != want[i] {
+			t.Fatalf("got calls %v, want %v", recorder.calls, want)
+		}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	n, err := parseNotifier("webhook=" + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.OnCycle(busEvent{Kind: eventCycleFinished, Cycle: 3, Err: errors.New("boom")}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != "cycle" || got.Cycle != 3 || got.Error != "boom" {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -0,0 +1,740 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ac "github.com/docker/docker/api/types/container"
+	flag "github.com/spf13/pflag"
+)
+
+type RatioValue struct {
+	Up   uint64
+	Down uint64
+	// UpPercent and DownPercent tell that Up and Down are percentages of
+	// the containers of the target, resolved each cycle.
+	UpPercent   bool
+	DownPercent bool
+}
+
+func (r *RatioValue) String() string {
+	if r.Up == 0 || r.Down == 0 {
+		return "1:1"
+	}
+	return formatRatioSide(r.Up, r.UpPercent) + ":" + formatRatioSide(r.Down, r.DownPercent)
+}
+
+func (r *RatioValue) Set(s string) error {
+	vars := strings.Split(s, ":")
+	if len(vars) != 2 {
+		return errors.New("wrong format")
+	}
+	up, upPercent, err := parseRatioSide(vars[0])
+	if err != nil {
+		return err
+	}
+	down, downPercent, err := parseRatioSide(vars[1])
+	if err != nil {
+		return err
+	}
+	if downPercent && down > 100 {
+		return fmt.Errorf("can not delete more than 100%% of the containers, got %v%%", down)
+	}
+	*r = RatioValue{Up: up, Down: down, UpPercent: upPercent, DownPercent: downPercent}
+	return nil
+}
+
+// parseRatioSide parses a number of containers, or a percentage of them,
+// e.g. 10%.
+func parseRatioSide(s string) (uint64, bool, error) {
+	percent := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseUint(strings.TrimSuffix(s, "%"), 10, 8)
+	return v, percent, err
+}
+
+func formatRatioSide(v uint64, percent bool) string {
+	if percent {
+		return fmt.Sprintf("%v%%", v)
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// resolve turns the percentages of the ratio into numbers of containers,
+// given the number of containers of the target, rounded to the nearest.
+func (r RatioValue) resolve(count int) RatioValue {
+	resolved := RatioValue{Up: r.Up, Down: r.Down}
+	if r.UpPercent {
+		resolved.Up = uint64(math.Round(float64(r.Up) * float64(count) / 100))
+	}
+	if r.DownPercent {
+		resolved.Down = uint64(math.Round(float64(r.Down) * float64(count) / 100))
+	}
+	return resolved
+}
+
+func (r *RatioValue) Type() string {
+	return "ratio"
+}
+
+func (r RatioValue) isZero() bool {
+	return r.Up == 0 || r.Down == 0
+}
+
+const (
+	modeChurn = "churn"
+	modeUp    = "up"
+	modeDown  = "down"
+)
+
+// options holds everything bubble can be configured with, either from the
+// command line or from a config file.
+type options struct {
+	command     string
+	config      string
+	backend     string
+	images      []string
+	targetsPoll time.Duration
+	targetsFile string
+	targets     []target
+
+	composeFile     string
+	composeProject  string
+	service         string
+	freq            time.Duration
+	ratio           RatioValue
+	mode            string
+	minAge          time.Duration
+	victimStrategy  string
+	states          []string
+	skipExec        bool
+	atomic          bool
+	transactionLog  string
+	undoCycles      int
+	simulateCycles  int
+	initial         int
+	selftestCycles  int
+	selftestImage   string
+	dashboard       string
+	selfUpdateTo    string
+	selfUpdateCheck bool
+	count           int
+	parallel        int
+
+	schedule []string
+	windows  []window
+	ramp     time.Duration
+
+	hosts      []string
+	apiVersion string
+	weights    []int
+	spread     string
+
+	maxCycles         int
+	duration          time.Duration
+	maxFailedCycles   int
+	probeURL          string
+	minProbeSuccess   float64
+	errorBudget       percentValue
+	errorBudgetMinOps int
+
+	output        string
+	statsInterval time.Duration
+	listen        string
+	apiTokens     []string
+	controlTokens []string
+	tlsCert       string
+	tlsKey        string
+	tlsClientCA   string
+	pushgateway   string
+	statsdAddr    string
+
+	archiveLogs          string
+	snapshotBeforeDelete bool
+	fromSnapshot         bool
+
+	gcAge       time.Duration
+	pruneImages bool
+
+	nomadAddr      string
+	nomadToken     string
+	nomadNamespace string
+	nomadJob       string
+	nomadGroup     string
+
+	ecsRegion  string
+	ecsCluster string
+	ecsService string
+
+	redisAddr     string
+	redisPassword string
+	leaderKey     string
+	leaderTTL     time.Duration
+	coordinateKey string
+	globalCount   int
+
+	lease   bool
+	daemon  bool
+	pidFile string
+	logFile string
+
+	interactive      bool
+	lock             string
+	diff             bool
+	strip            []string
+	cgroupParent     string
+	replaceUnhealthy bool
+	runtime          string
+	gpuPolicy        string
+	gpuIDs           []string
+	keepAliases      bool
+	restart          string
+	restartPolicy    *ac.RestartPolicy
+	isolatedNetwork  bool
+
+	fuzzRate         percentValue
+	fuzzMemoryJitter percentValue
+	clockSkew        percentValue
+	clockOffsets     []string
+	faketimeLib      string
+
+	pull         bool
+	registryAuth []string
+	dockerConfig string
+	verifyKey    string
+
+	overrideFile string
+	override     map[string]interface{}
+	patchFile    string
+	configPatch  []patchOperation
+
+	hostnameTemplate string
+	constraints      []string
+
+	readyProbeImage string
+	readyPort       int
+	readyLog        string
+	readyCmd        string
+	readyTimeout    time.Duration
+
+	healthcheckCmd      string
+	healthcheckInterval time.Duration
+
+	notify    []string
+	notifiers []Notifier
+
+	driftThreshold    int
+	externalPolicy    string
+	scopes            []string
+	scope             map[string]string
+	redactPatterns    []string
+	redactor          *redactor
+	logMode           string
+	debugEndpoints    bool
+	inspectCacheTTL   time.Duration
+	reconcileInterval time.Duration
+	waitCondition     string
+	waitTimeout       time.Duration
+	waitRetries       int
+	stateFile         string
+	standbyPool       int
+	priorityLabel     string
+	networkScope      string
+	hostPorts         string
+	hostPortRange     portRange
+	stripIPv6         bool
+	record            string
+	replay            string
+	socket            string
+	stopSignal        string
+	pausedPolicy      string
+	copySource        string
+	freezeSource      bool
+
+	add         string
+	addEvery    time.Duration
+	addRatio    RatioValue
+	remove      string
+	removeEvery time.Duration
+	removeRatio RatioValue
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVarP(&o.config, "config", "c", "", "config file, command line flags take precedence over its values")
+	fs.StringVar(&o.backend, "backend", backendDocker, "what is churned: docker containers, nomad allocations or ecs tasks")
+	fs.StringArrayVarP(&o.images, "image", "i", nil, "containers base on this image will be delete and start again, can be repeated with a weight, eg redis=3, the heavier the more often churned.")
+	fs.StringVar(&o.targetsFile, "targets-file", "", "file listing images to churn under an image key, reloaded when it changes")
+	fs.DurationVar(&o.targetsPoll, "targets-poll", 2*time.Second, "interval between checks of the targets file for changes")
+	fs.StringVar(&o.composeFile, "compose-file", "", "compose file declaring --service, its directory name is the default compose project")
+	fs.StringVar(&o.composeProject, "compose-project", "", "compose project of --service")
+	fs.StringVar(&o.service, "service", "", "compose service whose containers are churned, copies are numbered like docker compose up --scale ones")
+	fs.DurationVarP(&o.freq, "freq", "f", time.Minute, "frequency")
+	fs.VarP(&o.ratio, "ratio", "r", "ratio: x creation: y deletion. eg 1:2, 2:1, 1:1, or percentages of the containers of the target, eg 10%:10%")
+	fs.StringArrayVar(&o.schedule, "schedule", nil, "ratio applied during a daily time window instead of --ratio, eg 09:00-12:00=3:1, can be repeated")
+	fs.DurationVar(&o.ramp, "ramp", 0, "duration over which the per cycle creations and deletions grow linearly from zero to the ratio")
+	fs.StringVarP(&o.mode, "mode", "m", modeChurn, "churn creates and deletes containers, up only creates them and down only deletes them")
+	fs.DurationVar(&o.minAge, "min-age", 0, "only containers created at least this long ago can be deleted, e.g. 5m")
+	fs.StringVar(&o.victimStrategy, "victim-strategy", victimsUniform, "how victims are drawn: uniform, or age for a deletion probability proportional to their age")
+	fs.StringSliceVar(&o.states, "state", []string{stateRunning}, "states of the containers which are candidates, among running, paused and exited")
+	fs.BoolVar(&o.skipExec, "skip-exec", true, "do not delete containers with an active exec session, someone may be debugging in them")
+	fs.BoolVar(&o.atomic, "atomic", false, "remove the copies of a cycle when any of its operations fails, so that it changes nothing")
+	fs.StringVar(&o.transactionLog, "transaction-log", "", "file the containers created and removed by each cycle are recorded to, with the specs of removed ones, for undo")
+	fs.StringArrayVar(&o.hosts, "host", nil, "docker host to churn containers on, can be repeated, default to the environment one")
+	fs.StringVar(&o.apiVersion, "api-version", "", "docker api version used with the hosts, e.g. 1.40, negotiated with each host by default")
+	fs.IntSliceVar(&o.weights, "weights", nil, "weights of the hosts, in the order of --host, for the weighted spread")
+	fs.StringVar(&o.spread, "spread", spreadRoundRobin, "how copies are spread across hosts: round-robin or weighted")
+	fs.IntVar(&o.maxCycles, "max-cycles", 0, "stop after this number of cycles, 0 for no limit")
+	fs.DurationVar(&o.duration, "duration", 0, "stop after this duration with a summary, exiting 1 when the failure thresholds are exceeded")
+	fs.IntVar(&o.maxFailedCycles, "max-failed-cycles", -1, "number of failed cycles tolerated by --duration, negative for no limit")
+	fs.StringVar(&o.probeURL, "probe-url", "", "url checked after each cycle, it must answer with a 2xx or 3xx status")
+	fs.Float64Var(&o.minProbeSuccess, "min-probe-success", 0, "minimum probe success rate, between 0 and 1, required by --duration")
+	fs.IntVar(&o.errorBudgetMinOps, "error-budget-min-ops", 20, "number of operations before the error budget can be exceeded")
+	fs.Var(&o.errorBudget, "error-budget", "share of failed create and remove operations tolerated before aborting, eg 5%, by default any failure fails its cycle")
+	fs.StringVar(&o.output, "output", outputText, "text, or jsonl to also write one json object per created or removed container and per cycle to the standard output")
+	fs.DurationVar(&o.statsInterval, "stats-interval", 0, "sample cpu, memory and network usage of the containers of the targets at this interval and print them when bubble stops, 0 to disable")
+	fs.StringVar(&o.listen, "listen", "", "address serving the prometheus metrics on /metrics, e.g. :9090")
+	fs.StringArrayVar(&o.apiTokens, "api-token", nil, "bearer token granting read access to the listen address endpoints, can be repeated")
+	fs.StringArrayVar(&o.controlTokens, "control-token", nil, "bearer token granting read and control access, enabling the /pause and /resume endpoints, can be repeated")
+	fs.StringVar(&o.tlsCert, "tls-cert", "", "certificate served on the listen address with --tls-key")
+	fs.StringVar(&o.tlsKey, "tls-key", "", "key of --tls-cert")
+	fs.StringVar(&o.tlsClientCA, "tls-client-ca", "", "ca verifying client certificates, which are granted control access, enabling the /pause and /resume endpoints")
+	fs.StringVar(&o.pushgateway, "pushgateway", "", "prometheus pushgateway url the metrics are pushed to after each cycle")
+	fs.StringVar(&o.statsdAddr, "statsd", "", "statsd or dogstatsd address, host:port, the metrics are sent to after each cycle")
+	fs.StringVar(&o.archiveLogs, "archive-logs", "", "directory the logs of each deleted container are saved to before its removal")
+	fs.BoolVar(&o.snapshotBeforeDelete, "snapshot-before-delete", false, "commit each deleted container into an image tagged with the cycle and its id before its removal")
+	fs.BoolVar(&o.fromSnapshot, "from-snapshot", false, "commit the source once into bubble/<image>:snapshot and create copies from it, so they get its runtime filesystem changes")
+	fs.DurationVar(&o.gcAge, "gc-age", 0, "remove the copies of the cycle target which exited or died more than this duration ago, 0 to disable")
+	fs.BoolVar(&o.pruneImages, "prune-images", false, "remove the dangling images created by bubble when it stops")
+	fs.StringVar(&o.nomadAddr, "nomad-addr", defaultNomadAddr(), "address of the nomad api of the nomad backend")
+	fs.StringVar(&o.nomadToken, "nomad-token", os.Getenv("NOMAD_TOKEN"), "acl token of the nomad backend")
+	fs.StringVar(&o.nomadNamespace, "nomad-namespace", "", "namespace of the nomad job")
+	fs.StringVar(&o.nomadJob, "nomad-job", "", "nomad job whose allocations are churned by the nomad backend")
+	fs.StringVar(&o.nomadGroup, "nomad-group", "", "task group of the nomad job whose count is scaled and allocations stopped")
+	fs.StringVar(&o.ecsRegion, "ecs-region", defaultAWSRegion(), "aws region of the ecs backend")
+	fs.StringVar(&o.ecsCluster, "ecs-cluster", "", "ecs cluster of the ecs backend")
+	fs.StringVar(&o.ecsService, "ecs-service", "", "ecs service whose desired count is scaled and tasks stopped")
+	fs.StringVar(&o.coordinateKey, "coordinate-key", "", "redis key prefix bubbles churning different hosts divide the work of cycles under")
+	fs.IntVar(&o.globalCount, "global-count", 0, "number of containers of the target across the coordinated bubbles the cycles converge to, 0 to only churn")
+	fs.BoolVar(&o.lease, "lease", false, "take a lease on each host for each target, so that two bubbles never churn the same target on the same host, by creating a never started container of the target image on each host, pulling the image when missing")
+	fs.StringVar(&o.redisAddr, "redis", "", "redis address, host:port, of the store shared by redundant bubbles")
+	fs.StringVar(&o.redisPassword, "redis-password", os.Getenv("REDIS_PASSWORD"), "password of --redis")
+	fs.StringVar(&o.leaderKey, "leader-key", "", "redis key redundant bubbles elect their leader with, only the leader runs cycles")
+	fs.DurationVar(&o.leaderTTL, "leader-ttl", 15*time.Second, "how long the leadership lasts without being renewed, standbys take over after it")
+	fs.BoolVar(&o.daemon, "daemon", false, "detach from the terminal and run in the background")
+	fs.StringVar(&o.pidFile, "pid-file", "", "file the pid of bubble is written to, bubble refuses to start while the process it names runs")
+	fs.StringVar(&o.logFile, "log-file", "", "file logs are appended to instead of the standard error")
+	fs.BoolVar(&o.interactive, "interactive", false, "print each planned action and wait for confirmation before executing it")
+	fs.StringVar(&o.lock, "lock", "", "lock file, while it exists bubble pauses deletions and garbage collection, an emergency brake")
+	fs.StringSliceVar(&o.strip, "strip", nil, "host config fields removed from copies: "+strings.Join(stripperNames(), ","))
+	fs.BoolVar(&o.replaceUnhealthy, "replace-unhealthy", false, "replace containers of the targets as soon as docker reports them unhealthy, besides the cycles")
+	fs.StringVar(&o.cgroupParent, "cgroup-parent", "", "cgroup copies are placed under instead of the one of their source")
+	fs.StringVar(&o.runtime, "runtime", "", "oci runtime of the copies instead of the one of their source, e.g. runsc or kata")
+	fs.StringVar(&o.gpuPolicy, "gpu-policy", gpuShare, "gpu device requests of copies: share the source gpus, round-robin one gpu per copy or strip them")
+	fs.StringSliceVar(&o.gpuIDs, "gpu-ids", nil, "gpu ids handed out by the round-robin gpu policy, default to the ids requested by the source")
+	fs.StringVar(&o.restart, "restart", "", "restart policy of copies instead of the source one: no, on-failure[:max-retries], always or unless-stopped")
+	fs.BoolVar(&o.keepAliases, "keep-aliases", false, "keep the network aliases of the source as is on copies, for dns round robin, instead of making them unique")
+	fs.Var(&o.fuzzRate, "fuzz", "share of copies created with a perturbed config, shuffled environment, random labels and jittered memory limit, e.g. 10%")
+	o.fuzzMemoryJitter = percentValue{value: 0.2, set: true}
+	fs.Var(&o.fuzzMemoryJitter, "fuzz-memory-jitter", "how much the memory limit of fuzzed copies varies at most, up or down")
+	fs.Var(&o.clockSkew, "clock-skew", "share of copies created with a skewed clock, through the FAKETIME variable of libfaketime, e.g. 10%")
+	fs.StringSliceVar(&o.clockOffsets, "clock-offsets", []string{"+5m", "-5m"}, "clock offsets skewed copies get one of at random, e.g. +5m,-30s,+1d")
+	fs.StringVar(&o.faketimeLib, "faketime-lib", "", "libfaketime path on the host, mounted into skewed copies and preloaded, when their image does not preload it")
+	fs.BoolVar(&o.isolatedNetwork, "isolated-network", false, "attach each copy to a bridge network of its own, removed with the copy")
+	fs.BoolVar(&o.pull, "pull", false, "pull the image of the source on the hosts of its copies before creating them")
+	fs.StringArrayVar(&o.registryAuth, "registry-auth", nil, "registry credentials for --pull, [registry=]user:password, can be repeated")
+	fs.StringVar(&o.dockerConfig, "docker-config", defaultDockerConfig(), "docker config file the registry credentials for --pull are read from, including credential helpers")
+	fs.StringVar(&o.verifyKey, "verify-key", "", "cosign public key the image digest of the source must be signed with, copies are refused otherwise")
+	fs.StringVar(&o.overrideFile, "override", "", "json file with partial Config, HostConfig and NetworkingConfig merge patched onto the config of copies")
+	fs.StringVar(&o.patchFile, "config-patch", "", "json patch (rfc 6902) file applied to the create payload of copies, after --override")
+	fs.StringVar(&o.hostnameTemplate, "hostname-template", "{{.Name}}", "hostname of copies, a go template with .Name, .Source, .Suffix and .Image")
+	fs.StringArrayVar(&o.constraints, "constraint", nil, "placement constraint copies are subject to, e.g. node.labels.zone==a, can be repeated")
+	fs.StringVar(&o.readyProbeImage, "ready-probe-image", "", "image with nc run in the network namespace of copies to check --ready-port, e.g. busybox, needed when bubble can not reach the copies")
+	fs.IntVar(&o.readyPort, "ready-port", 0, "tcp port a copy must accept connections on to be considered created, it is removed otherwise")
+	fs.StringVar(&o.readyLog, "ready-log", "", "text a log line of a copy must contain for it to be considered created, it is removed otherwise")
+	fs.StringVar(&o.readyCmd, "ready-cmd", "", "command run by the shell of a copy until it succeeds for the copy to be considered created, it is removed otherwise")
+	fs.DurationVar(&o.readyTimeout, "ready-timeout", time.Minute, "how long a copy has to become ready")
+	fs.StringVar(&o.healthcheckCmd, "healthcheck-cmd", "", "healthcheck command of copies, run by the container shell, instead of the source one")
+	fs.DurationVar(&o.healthcheckInterval, "healthcheck-interval", 0, "healthcheck interval of copies instead of the source one")
+	fs.StringArrayVar(&o.notify, "notify", nil, "notification target of the cycles, failures and stop of bubble, name[=url] among "+strings.Join(notifierNames(), ", ")+", can be repeated")
+	fs.IntVar(&o.driftThreshold, "drift-threshold", 0, "alert when the containers of a target differ by this number or more from the ones expected after the cycles, 0 to disable")
+	fs.StringVar(&o.externalPolicy, "external-policy", externalAdopt, "what to do with containers of the targets created by other tools during the run: adopt them into the churned ones, ignore them or alert and not churn them")
+	fs.StringArrayVar(&o.scopes, "scope", nil, "label, key=value, the containers of the targets must have and copies are stamped with, so that teams sharing hosts do not churn each other containers, can be repeated")
+	fs.StringArrayVar(&o.redactPatterns, "redact", defaultRedactPatterns, "regular expression of the environment variables, labels and other keys whose values are redacted from logs, config diffs, actions and notifications, can be repeated")
+	fs.StringVar(&o.logMode, "log-mode", logModeFull, "full logs a line for every container created and removed, summary a line per cycle with their counts, for high churn")
+	fs.BoolVar(&o.debugEndpoints, "debug-endpoints", false, "serve the pprof profiles on /debug/pprof/ and the expvar variables on /debug/vars of the listen address")
+	fs.DurationVar(&o.inspectCacheTTL, "inspect-cache-ttl", time.Minute, "how long the inspection of a source is reused by the next cycles copying it, invalidated earlier by its container events, 0 to disable")
+	fs.DurationVar(&o.reconcileInterval, "reconcile-interval", 0, "keep the candidates up to date from the container events, listing them again at this interval, instead of listing them every cycle, 0 to disable")
+	fs.StringVar(&o.waitCondition, "wait-condition", waitNotRunning, "what removed containers are waited for before the next one: not-running once stopped, or removed once removed too")
+	fs.DurationVar(&o.waitTimeout, "wait-timeout", time.Minute, "how long a removed container is waited for")
+	fs.IntVar(&o.waitRetries, "wait-retries", 2, "number of times the wait for a removed container is retried when it fails, e.g. when the connection to the daemon drops")
+	fs.StringVar(&o.add, "add", "", "number of copies, or percentage of the containers of the target, created every --add-every, independently of deletions, instead of the ratio")
+	fs.DurationVar(&o.addEvery, "add-every", 0, "interval between the creations of --add")
+	fs.StringVar(&o.remove, "remove", "", "number of containers, or percentage of the containers of the target, deleted every --remove-every, independently of creations, instead of the ratio")
+	fs.DurationVar(&o.removeEvery, "remove-every", 0, "interval between the deletions of --remove")
+	fs.StringVar(&o.stateFile, "state-file", "", "file the counters, cycle and operation counts and exits are saved to after every cycle and restored from on start, so that they survive a restart")
+	fs.IntVar(&o.standbyPool, "standby-pool", 0, "number of copies of each target kept created but stopped on the hosts copies are created on, so that creating a copy only starts one of them while the pool is replenished in the background")
+	fs.StringVar(&o.priorityLabel, "priority-label", "bubble.priority", "label giving the priority class of containers, low, normal or high: low priority containers are preferred victims and high priority ones are only deleted when no other is left")
+	fs.StringVar(&o.networkScope, "network-scope", "", "only churn the containers attached to this network, and attach copies to this network only")
+	fs.StringVar(&o.hostPorts, "host-ports", "", "range of host ports, e.g. 30000-30999, the published ports of copies are allocated from instead of the daemon choosing them")
+	fs.BoolVar(&o.stripIPv6, "strip-ipv6", false, "attach copies without the static ipv6 addresses of their source, the daemon allocating them, instead of new static addresses in the same subnet")
+	fs.StringVar(&o.record, "record", "", "file every request to the daemons and their responses are recorded to, redacted, to be replayed with --replay")
+	fs.StringVar(&o.replay, "replay", "", "file of recorded requests whose responses are served back instead of reaching the daemons, to run bubble without daemon")
+	fs.StringVar(&o.socket, "socket", "", "socket of the local docker daemon, a unix socket path or a named pipe, e.g. npipe:////./pipe/docker_engine, detected among the ones of rootless docker, colima, rancher desktop and docker desktop by default")
+	fs.StringVar(&o.stopSignal, "stop-signal", "", "signal victims are stopped with, e.g. SIGQUIT, instead of their own stop signal, the one of their image or SIGTERM, before being killed after their stop timeout")
+	fs.StringVar(&o.pausedPolicy, "paused", pausedUnpause, "what is done with paused candidates, with --state paused: skip them, unpause victims before stopping them, or pick them as victims first and kill them")
+	fs.StringVar(&o.copySource, "copy-source", sourceRandom, "candidate copies are derived from: the newest, the oldest, a random one among the healthiest, a random one, or the original one, not created by bubble")
+	fs.BoolVar(&o.freezeSource, "freeze-source", false, "capture the config of the source of each target once on startup and copy it for every copy, so that later changes to the source do not leak into copies")
+	fs.BoolVar(&o.diff, "diff", false, "log the differences between the source config and the config submitted for its copies")
+}
+
+// newFlagSet returns the flags of the command, bound to new options.
+func newFlagSet(name string) (*options, *flag.FlagSet) {
+	o := &options{command: name}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", name)
+		fs.PrintDefaults()
+	}
+	o.register(fs)
+	switch name {
+	case "undo":
+		fs.IntVar(&o.undoCycles, "cycles", 1, "number of last cycles to undo")
+	case "simulate":
+		fs.IntVar(&o.simulateCycles, "cycles", 100, "number of cycles to simulate")
+		fs.IntVar(&o.initial, "initial", 10, "number of containers of the target when the simulation starts")
+	case "bench":
+		fs.IntVar(&o.count, "count", 50, "number of copies created then removed")
+	case "scale-up":
+		fs.IntVar(&o.count, "count", 10, "number of copies created")
+		fs.IntVar(&o.parallel, "parallel", 4, "number of copies created at the same time")
+	case "dashboard":
+		fs.StringVar(&o.dashboard, "format", dashboardGrafana, "format of the dashboard, grafana")
+	case "self-update":
+		fs.StringVar(&o.selfUpdateTo, "to", "", "tag of the release to install instead of the latest one")
+		fs.BoolVar(&o.selfUpdateCheck, "check", false, "only tell whether another release is available")
+	case "selftest":
+		fs.IntVar(&o.selftestCycles, "cycles", 3, "number of cycles churning the target container")
+		fs.StringVar(&o.selftestImage, "target-image", "nginx:alpine", "image of the disposable target container")
+	}
+	return o, fs
+}
+
+// parseOptions parses args for the given command, then fills the flags that
+// were not given on the command line from the config file if any.
+func parseOptions(name string, args []string) (*options, *flag.FlagSet, error) {
+	o, fs := newFlagSet(name)
+	if err := fs.Parse(args); err != nil {
+		return nil, fs, err
+	}
+	if o.config != "" {
+		if err := loadConfig(o.config, fs); err != nil {
+			return nil, fs, err
+		}
+	}
+	if o.ratio.isZero() {
+		o.ratio = RatioValue{Up: 1, Down: 1}
+	}
+	return o, fs, nil
+}
+
+// check verifies the options are consistent before talking to docker.
+func (o *options) check() error {
+	redactor, err := newRedactor(o.redactPatterns)
+	if err != nil {
+		return err
+	}
+	o.redactor = redactor
+	if o.command == "undo" {
+		if o.transactionLog == "" {
+			return errors.New("undo requires a transaction log")
+		}
+		if o.undoCycles < 1 {
+			return fmt.Errorf("number of cycles to undo must be positive, got %v", o.undoCycles)
+		}
+		return checkSpread(o)
+	}
+	if err := checkBackend(o); err != nil {
+		return err
+	}
+	if (o.command == "bench" || o.command == "scale-up") && o.count < 1 {
+		return fmt.Errorf("number of copies must be positive, got %v", o.count)
+	}
+	if o.command == "scale-up" && o.parallel < 1 {
+		return fmt.Errorf("parallelism must be positive, got %v", o.parallel)
+	}
+	if o.command == "simulate" {
+		if o.simulateCycles < 1 || o.initial < 0 {
+			return errors.New("simulation requires a positive number of cycles and of initial containers")
+		}
+	} else if o.command == "selftest" {
+		if o.backend != backendDocker {
+			return errors.New("selftest requires the docker backend")
+		}
+		if o.selftestCycles < 1 {
+			return fmt.Errorf("number of cycles must be positive, got %v", o.selftestCycles)
+		}
+	} else if o.backend == backendDocker && len(o.images) == 0 && o.targetsFile == "" && o.service == "" {
+		return errors.New("image argument is empty")
+	}
+	o.targets = nil
+	for _, image := range o.images {
+		t, err := parseTarget(image)
+		if err != nil {
+			return err
+		}
+		o.targets = append(o.targets, t)
+	}
+	if o.service != "" {
+		t, err := o.composeTarget()
+		if err != nil {
+			return err
+		}
+		o.targets = append(o.targets, t)
+	}
+	if o.targetsFile != "" {
+		targets, err := loadTargets(o.targetsFile)
+		if err != nil {
+			return err
+		}
+		o.targets = append(o.targets, targets...)
+		if o.targetsPoll <= 0 {
+			return fmt.Errorf("targets poll interval must be positive, got %v", o.targetsPoll)
+		}
+	}
+	scope, err := parseScope(o.scopes)
+	if err != nil {
+		return err
+	}
+	if scope != nil && o.backend != backendDocker {
+		return errors.New("scope requires the docker backend")
+	}
+	if err := checkNetworkScope(o); err != nil {
+		return err
+	}
+	o.scope = scope
+	o.targets = networkScoped(scoped(o.targets, scope), o.networkScope)
+	if o.command == "bench" || o.command == "scale-up" {
+		if o.backend != backendDocker {
+			return fmt.Errorf("%s requires the docker backend", o.command)
+		}
+		if len(o.targets) == 0 {
+			return fmt.Errorf("%s requires an image or a compose service", o.command)
+		}
+	}
+	if o.freq <= 0 {
+		return fmt.Errorf("frequency must be positive, got %v", o.freq)
+	}
+	if o.ramp < 0 {
+		return fmt.Errorf("ramp must be positive, got %v", o.ramp)
+	}
+	if o.maxCycles < 0 {
+		return fmt.Errorf("maximum number of cycles must be positive, got %v", o.maxCycles)
+	}
+	if o.statsInterval < 0 {
+		return fmt.Errorf("stats interval must be positive, got %v", o.statsInterval)
+	}
+	if (o.tlsCert == "") != (o.tlsKey == "") {
+		return errors.New("tls cert and tls key must be given together")
+	}
+	if o.tlsClientCA != "" && o.tlsCert == "" {
+		return errors.New("tls client ca requires a tls cert")
+	}
+	if o.leaderKey != "" && o.redisAddr == "" {
+		return errors.New("leader election requires redis")
+	}
+	if o.coordinateKey != "" && o.redisAddr == "" {
+		return errors.New("coordination requires redis")
+	}
+	if o.coordinateKey != "" && o.backend != backendDocker {
+		return errors.New("coordination requires the docker backend")
+	}
+	if o.globalCount < 0 {
+		return fmt.Errorf("global count must be positive, got %v", o.globalCount)
+	}
+	if o.leaderTTL < 3*time.Millisecond {
+		return fmt.Errorf("leader ttl is too short, got %v", o.leaderTTL)
+	}
+	if o.daemon && o.interactive {
+		return errors.New("daemon can not be interactive")
+	}
+	if o.archiveLogs != "" {
+		if info, err := os.Stat(o.archiveLogs); err != nil || !info.IsDir() {
+			return fmt.Errorf("archive logs directory %s does not exist", o.archiveLogs)
+		}
+	}
+	if o.minAge < 0 {
+		return fmt.Errorf("minimum age must be positive, got %v", o.minAge)
+	}
+	if o.gcAge < 0 {
+		return fmt.Errorf("gc age must be positive, got %v", o.gcAge)
+	}
+	if o.duration < 0 {
+		return fmt.Errorf("duration must be positive, got %v", o.duration)
+	}
+	if o.minProbeSuccess < 0 || o.minProbeSuccess > 1 {
+		return fmt.Errorf("minimum probe success rate must be between 0 and 1, got %v", o.minProbeSuccess)
+	}
+	o.windows = nil
+	for _, s := range o.schedule {
+		w, err := parseWindow(s)
+		if err != nil {
+			return err
+		}
+		o.windows = append(o.windows, w)
+	}
+	switch o.mode {
+	case modeChurn, modeUp, modeDown:
+	default:
+		return fmt.Errorf("unknown mode %q, expected %s, %s or %s", o.mode, modeChurn, modeUp, modeDown)
+	}
+	if err := checkOutput(o.output); err != nil {
+		return err
+	}
+	o.notifiers = nil
+	for _, s := range o.notify {
+		n, err := parseNotifier(s)
+		if err != nil {
+			return err
+		}
+		o.notifiers = append(o.notifiers, n)
+	}
+	if o.verifyKey != "" && o.fromSnapshot {
+		return fmt.Errorf("--verify-key can not be used with --from-snapshot, snapshots are not signed")
+	}
+	if o.driftThreshold < 0 {
+		return fmt.Errorf("drift threshold must not be negative, got %v", o.driftThreshold)
+	}
+	if o.driftThreshold > 0 && o.coordinateKey != "" {
+		return errors.New("drift alerting can not be used with coordination, the other bubbles change the containers")
+	}
+	if o.errorBudgetMinOps < 0 {
+		return fmt.Errorf("error budget minimum operations must not be negative")
+	}
+	if err := checkClockOffsets(o.clockOffsets); err != nil {
+		return err
+	}
+	if o.debugEndpoints && o.listen == "" && os.Getenv("LISTEN_FDS") == "" {
+		return errors.New("debug endpoints require a listen address")
+	}
+	if o.inspectCacheTTL < 0 {
+		return fmt.Errorf("inspect cache ttl must not be negative, got %v", o.inspectCacheTTL)
+	}
+	if o.reconcileInterval < 0 {
+		return fmt.Errorf("reconcile interval must not be negative, got %v", o.reconcileInterval)
+	}
+	if err := checkIndependent(o); err != nil {
+		return err
+	}
+	if err := checkWait(o); err != nil {
+		return err
+	}
+	if err := checkStandby(o); err != nil {
+		return err
+	}
+	if err := checkRecording(o); err != nil {
+		return err
+	}
+	if err := checkSocket(o); err != nil {
+		return err
+	}
+	if err := checkStopSignal(o.stopSignal); err != nil {
+		return err
+	}
+	if err := checkPausedPolicy(o.pausedPolicy); err != nil {
+		return err
+	}
+	if err := checkCopySource(o.copySource); err != nil {
+		return err
+	}
+	if err := checkFreezeSource(o); err != nil {
+		return err
+	}
+	if o.hostPortRange, err = parsePortRange(o.hostPorts); err != nil {
+		return err
+	}
+	if err := checkLogMode(o.logMode); err != nil {
+		return err
+	}
+	if err := checkExternalPolicy(o.externalPolicy); err != nil {
+		return err
+	}
+	if err := checkStates(o.states); err != nil {
+		return err
+	}
+	if err := checkVictimStrategy(o.victimStrategy); err != nil {
+		return err
+	}
+	if err := checkSpread(o); err != nil {
+		return err
+	}
+	if err := checkStrip(o.strip); err != nil {
+		return err
+	}
+	if err := checkGPUPolicy(o.gpuPolicy); err != nil {
+		return err
+	}
+	o.restartPolicy = nil
+	if o.restart != "" {
+		policy, err := parseRestartPolicy(o.restart)
+		if err != nil {
+			return err
+		}
+		o.restartPolicy = &policy
+	}
+	if o.overrideFile != "" {
+		override, err := loadOverride(o.overrideFile)
+		if err != nil {
+			return err
+		}
+		o.override = override
+	}
+	if o.patchFile != "" {
+		ops, err := loadJSONPatch(o.patchFile)
+		if err != nil {
+			return err
+		}
+		o.configPatch = ops
+	}
+	if _, err := parseHostnameTemplate(o.hostnameTemplate); err != nil {
+		return err
+	}
+	for _, s := range o.constraints {
+		if _, err := parseConstraint(s); err != nil {
+			return err
+		}
+	}
+	if o.readyPort < 0 || o.readyPort > 65535 {
+		return fmt.Errorf("invalid ready port %v", o.readyPort)
+	}
+	if o.readyTimeout <= 0 {
+		return fmt.Errorf("ready timeout must be positive, got %v", o.readyTimeout)
+	}
+	if o.healthcheckInterval < 0 {
+		return fmt.Errorf("healthcheck interval must be positive, got %v", o.healthcheckInterval)
+	}
+	return nil
+}
+
+func (o *options) composeTarget() (target, error) {
+	t := target{project: o.composeProject, service: o.service, weight: 1}
+	if o.composeFile != "" {
+		if err := checkComposeService(o.composeFile, o.service); err != nil {
+			return t, err
+		}
+		if t.project == "" {
+			project, err := composeProjectName(o.composeFile)
+			if err != nil {
+				return t, fmt.Errorf("could not get compose project name: %w", err)
+			}
+			t.project = project
+		}
+	}
+	if t.project == "" {
+		return t, errors.New("service needs a compose file or a compose project")
+	}
+	return t, nil
+}
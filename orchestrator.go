@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	backendDocker = "docker"
+	backendNomad  = "nomad"
+	backendECS    = "ecs"
+)
+
+// orchestrator is a scheduler whose units bubble churns instead of docker
+// containers: it scales the desired count of a group of units and stops
+// random units, which the scheduler replaces.
+type orchestrator interface {
+	String() string
+	// count returns the desired count of the group.
+	count() (int, error)
+	// units returns the ids of the running units of the group.
+	units() ([]string, error)
+	scale(count int) error
+	stop(id string) error
+}
+
+// newOrchestrator returns the orchestrator of the backend, nil for docker.
+func newOrchestrator(opts *options) orchestrator {
+	switch opts.backend {
+	case backendNomad:
+		return newNomad(opts)
+	case backendECS:
+		return newECS(opts)
+	}
+	return nil
+}
+
+func checkBackend(opts *options) error {
+	switch opts.backend {
+	case backendDocker:
+	case backendNomad:
+		if opts.nomadJob == "" || opts.nomadGroup == "" {
+			return fmt.Errorf("nomad backend requires a job and a group")
+		}
+	case backendECS:
+		if opts.ecsCluster == "" || opts.ecsService == "" || opts.ecsRegion == "" {
+			return fmt.Errorf("ecs backend requires a cluster, a service and a region")
+		}
+		// credentials are resolved like the aws sdks do, not only from the
+		// environment.
+		if _, err := newAWSCredentialsProvider().get(); err != nil {
+			return fmt.Errorf("ecs backend requires aws credentials: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown backend %q, expected %s, %s or %s", opts.backend, backendDocker, backendNomad, backendECS)
+	}
+	return nil
+}
+
+// orchestratorJob is the cycle of orchestrator backends: it stops the
+// victims, replaced by the scheduler, then scales the group by the
+// difference between creations and deletions.
+func (r *runner) orchestratorJob(ratio *RatioValue) error {
+	o, opts := r.orchestrator, r.opts
+	now := time.Now()
+	units, err := o.units()
+	if err != nil {
+		return err
+	}
+	cycleRatio := rampRatio(r.ratioAt(ratio, now), now.Sub(r.started), opts.ramp).resolve(len(units))
+	up, down := int(cycleRatio.Up), int(cycleRatio.Down)
+	switch opts.mode {
+	case modeUp:
+		down = 0
+	case modeDown:
+		up = 0
+	}
+	registry.set(metricCandidates, float64(len(units)), label{"target", o.String()})
+	if down > len(units) {
+		return fmt.Errorf("can not delete %v units when exists only %v", down, len(units))
+	}
+	count, err := o.count()
+	if err != nil {
+		return err
+	}
+	if locked(opts) {
+		logrus.WithField("lock", opts.lock).Warn("lock held, deletions paused")
+		down = 0
+	}
+	for _, i := range rand.Perm(len(units))[:down] {
+		err := o.stop(units[i])
+		if err == nil {
+			logrus.WithField("unit", units[i]).WithField("target", o.String()).Info("stop unit")
+			bus.publish(busEvent{Kind: eventContainerRemoved, Container: units[i], Host: o.String()})
+		}
+		if err := r.budget.record(err); err != nil {
+			return err
+		}
+	}
+	if up == down {
+		return nil
+	}
+	desired := count + up - down
+	if desired < 0 {
+		desired = 0
+	}
+	err = o.scale(desired)
+	if err == nil {
+		logrus.WithField("target", o.String()).WithField("from", count).WithField("to", desired).Info("scale group")
+		for i := count; i < desired; i++ {
+			bus.publish(busEvent{Kind: eventContainerCreated, Host: o.String()})
+		}
+	}
+	return r.budget.record(err)
+}
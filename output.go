@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	outputText  = "text"
+	outputJSONL = "jsonl"
+)
+
+// action is a line of the jsonl action stream.
+type action struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Cycle   int       `json:"cycle,omitempty"`
+	CycleID string    `json:"cycle_id,omitempty"`
+	// CorrelationID identifies the actions on a same container.
+	CorrelationID string  `json:"correlation_id,omitempty"`
+	Container     string  `json:"container,omitempty"`
+	Host          string  `json:"host,omitempty"`
+	Image         string  `json:"image,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	Duration      float64 `json:"duration_seconds,omitempty"`
+}
+
+func checkOutput(output string) error {
+	if output != outputText && output != outputJSONL {
+		return fmt.Errorf("unknown output %q, expected %s or %s", output, outputText, outputJSONL)
+	}
+	return nil
+}
+
+// actionWriter writes the action stream, subscribed to the bus when
+// --output is jsonl.
+type actionWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func startActions(w io.Writer) {
+	bus.subscribe(&actionWriter{encoder: json.NewEncoder(w)})
+}
+
+// actionEvents are the actions of the bus events, the others are not part of
+// the stream.
+var actionEvents = map[string]string{
+	eventContainerCreated:  "create",
+	eventContainerRemoved:  "remove",
+	eventContainerReplaced: "replace",
+	eventCycleFinished:     "cycle",
+}
+
+func (w *actionWriter) handle(e busEvent) {
+	event, ok := actionEvents[e.Kind]
+	if !ok {
+		return
+	}
+	a := action{Time: e.Time, Event: event, Cycle: e.Cycle, CycleID: e.CycleID, CorrelationID: e.CorrelationID, Container: e.Container, Host: e.Host, Image: e.Image, Duration: e.Duration.Seconds()}
+	if e.Err != nil {
+		a.Error = e.Err.Error()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.encoder.Encode(a); err != nil {
+		logrus.WithError(err).Debug("could not write action")
+	}
+}
+
+// report is where the reports printed when bubble stops are written: the
+// standard output, unless it carries the action stream.
+func (r *runner) report() io.Writer {
+	if r.opts.output == outputJSONL {
+		return os.Stderr
+	}
+	return os.Stdout
+}
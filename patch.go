@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// loadOverride reads a merge patch document, in json, which is also valid
+// yaml, with Config, HostConfig and NetworkingConfig top level keys.
+func loadOverride(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read override file %s: %w", path, err)
+	}
+	var override map[string]interface{}
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("could not decode override file %s: %w", path, err)
+	}
+	for key := range override {
+		switch key {
+		case "Config", "HostConfig", "NetworkingConfig":
+		default:
+			return nil, fmt.Errorf("override file %s: unknown key %q, expected Config, HostConfig or NetworkingConfig", path, key)
+		}
+	}
+	return override, nil
+}
+
+// mergePatch applies a json merge patch, as defined by rfc 7386, to target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+	return t
+}
+
+// applyOverride merge patches the spec with the override document.
+func applyOverride(spec *copySpec, override map[string]interface{}) error {
+	return patchSpec(spec, func(doc interface{}) (interface{}, error) {
+		return mergePatch(doc, override), nil
+	})
+}
+
+// patchSpec applies a patch function to the json representation of the spec.
+func patchSpec(spec *copySpec, patch func(interface{}) (interface{}, error)) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("could not encode spec: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("could not decode spec: %w", err)
+	}
+	if doc, err = patch(doc); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return fmt.Errorf("could not encode patched spec: %w", err)
+	}
+	var patched copySpec
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return fmt.Errorf("could not decode patched spec: %w", err)
+	}
+	*spec = patched
+	return nil
+}
+
+// patchOperation is an operation of a json patch, as defined by rfc 6902.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+func loadJSONPatch(path string) ([]patchOperation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config patch %s: %w", path, err)
+	}
+	var ops []patchOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("could not decode config patch %s: %w", path, err)
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "remove", "replace", "move", "copy", "test":
+		default:
+			return nil, fmt.Errorf("config patch %s: operation %v: unknown op %q", path, i, op.Op)
+		}
+	}
+	return ops, nil
+}
+
+// applyJSONPatch applies the operations to the create payload of the copy.
+func applyJSONPatch(spec *copySpec, ops []patchOperation) error {
+	return patchSpec(spec, func(doc interface{}) (interface{}, error) {
+		var err error
+		for i, op := range ops {
+			if doc, err = applyOperation(doc, op); err != nil {
+				return nil, fmt.Errorf("operation %v %s %s: %w", i, op.Op, op.Path, err)
+			}
+		}
+		return doc, nil
+	})
+}
+
+func applyOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return pointerSet(doc, op.Path, op.Value, true)
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+	case "replace":
+		if _, err := pointerGet(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, op.Value, false)
+	case "move":
+		doc, value, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, value, true)
+	case "copy":
+		value, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, deepCopy(value), true)
+	case "test":
+		value, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.Value) {
+			return nil, fmt.Errorf("test failed, value is %v", value)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+func deepCopy(v interface{}) interface{} {
+	data, _ := json.Marshal(v)
+	var c interface{}
+	json.Unmarshal(data, &c)
+	return c
+}
+
+// splitPointer splits a json pointer, as defined by rfc 6901, into tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", pointer)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path %s not found", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerSet sets the value at pointer, inserting it in arrays when insert
+// is true, and returns the updated document.
+func pointerSet(doc interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return setTokens(doc, tokens, value, insert, pointer)
+}
+
+func setTokens(doc interface{}, tokens []string, value interface{}, insert bool, pointer string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) > 0 {
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", pointer)
+			}
+			updated, err := setTokens(child, rest, value, insert, pointer)
+			if err != nil {
+				return nil, err
+			}
+			node[token] = updated
+			return node, nil
+		}
+		node[token] = value
+		return node, nil
+	case []interface{}:
+		last := len(rest) == 0
+		i, err := arrayIndex(token, len(node), last && insert)
+		if err != nil {
+			return nil, err
+		}
+		if !last {
+			updated, err := setTokens(node[i], rest, value, insert, pointer)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = updated
+			return node, nil
+		}
+		if !insert {
+			node[i] = value
+			return node, nil
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return node, nil
+	}
+	return nil, fmt.Errorf("path %s not found", pointer)
+}
+
+// pointerRemove removes the value at pointer and returns the updated
+// document with the removed value.
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	value, err := pointerGet(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, _ := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return nil, value, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		delete(node, last)
+		return doc, value, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		node = append(node[:i], node[i+1:]...)
+		doc, err = setTokens(doc, tokens[:len(tokens)-1], node, false, parentPointer)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("path %s not found", pointer)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid json %s: %v", s, err)
+	}
+	return v
+}
+
+func TestApplyOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		op   patchOperation
+		want string
+		err  bool
+	}{
+		{"add key", `{"a":1}`, patchOperation{Op: "add", Path: "/b", Value: 2.0}, `{"a":1,"b":2}`, false},
+		{"add replaces key", `{"a":1}`, patchOperation{Op: "add", Path: "/a", Value: 2.0}, `{"a":2}`, false},
+		{"add to array end", `{"a":[1,2]}`, patchOperation{Op: "add", Path: "/a/-", Value: 3.0}, `{"a":[1,2,3]}`, false},
+		{"add inserts in array", `{"a":[1,3]}`, patchOperation{Op: "add", Path: "/a/1", Value: 2.0}, `{"a":[1,2,3]}`, false},
+		{"add at array length", `{"a":[1]}`, patchOperation{Op: "add", Path: "/a/1", Value: 2.0}, `{"a":[1,2]}`, false},
+		{"add past array length", `{"a":[1]}`, patchOperation{Op: "add", Path: "/a/2", Value: 2.0}, ``, true},
+		{"add to missing parent", `{}`, patchOperation{Op: "add", Path: "/a/b", Value: 1.0}, ``, true},
+		{"add escaped key", `{}`, patchOperation{Op: "add", Path: "/a~1b~0c", Value: 1.0}, `{"a/b~c":1}`, false},
+		{"remove key", `{"a":1,"b":2}`, patchOperation{Op: "remove", Path: "/a"}, `{"b":2}`, false},
+		{"remove in array", `{"a":[1,2,3]}`, patchOperation{Op: "remove", Path: "/a/1"}, `{"a":[1,3]}`, false},
+		{"remove nested array", `{"a":{"b":[1,2]}}`, patchOperation{Op: "remove", Path: "/a/b/0"}, `{"a":{"b":[2]}}`, false},
+		{"remove array end", `{"a":[1]}`, patchOperation{Op: "remove", Path: "/a/-"}, ``, true},
+		{"remove missing", `{"a":1}`, patchOperation{Op: "remove", Path: "/b"}, ``, true},
+		{"replace", `{"a":1}`, patchOperation{Op: "replace", Path: "/a", Value: "x"}, `{"a":"x"}`, false},
+		{"replace in array", `{"a":[1,2]}`, patchOperation{Op: "replace", Path: "/a/0", Value: 0.0}, `{"a":[0,2]}`, false},
+		{"replace missing", `{"a":1}`, patchOperation{Op: "replace", Path: "/b", Value: 1.0}, ``, true},
+		{"move key", `{"a":1,"b":{}}`, patchOperation{Op: "move", From: "/a", Path: "/b/c"}, `{"b":{"c":1}}`, false},
+		{"move in array", `{"a":[1,2,3]}`, patchOperation{Op: "move", From: "/a/0", Path: "/a/-"}, `{"a":[2,3,1]}`, false},
+		{"move missing", `{"a":1}`, patchOperation{Op: "move", From: "/b", Path: "/c"}, ``, true},
+		{"copy", `{"a":{"b":1}}`, patchOperation{Op: "copy", From: "/a", Path: "/c"}, `{"a":{"b":1},"c":{"b":1}}`, false},
+		{"test equal", `{"a":[1,"x"]}`, patchOperation{Op: "test", Path: "/a", Value: []interface{}{1.0, "x"}}, `{"a":[1,"x"]}`, false},
+		{"test different", `{"a":1}`, patchOperation{Op: "test", Path: "/a", Value: 2.0}, ``, true},
+		{"test missing", `{"a":1}`, patchOperation{Op: "test", Path: "/b", Value: 1.0}, ``, true},
+		{"invalid pointer", `{"a":1}`, patchOperation{Op: "add", Path: "a", Value: 1.0}, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyOperation(decode(t, tt.doc), tt.op)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"set key", `{"a":1}`, `{"b":2}`, `{"a":1,"b":2}`},
+		{"remove key", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"nested", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`},
+		{"arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"scalar replaces object", `{"a":{"b":1}}`, `{"a":1}`, `{"a":1}`},
+		{"object replaces scalar", `{"a":1}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergePatch(decode(t, tt.target), decode(t, tt.patch))
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
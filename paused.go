@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	pausedSkip    = "skip"
+	pausedUnpause = "unpause"
+	pausedVictim  = "victim"
+)
+
+func checkPausedPolicy(policy string) error {
+	switch policy {
+	case pausedSkip, pausedUnpause, pausedVictim:
+		return nil
+	}
+	return fmt.Errorf("unknown paused policy %q, expected %s, %s or %s", policy, pausedSkip, pausedUnpause, pausedVictim)
+}
+
+func splitPaused(candidates []candidate) (paused, others []candidate) {
+	for _, c := range candidates {
+		if c.State == statePaused {
+			paused = append(paused, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	return paused, others
+}
+
+// pickPlanVictims draws n distinct victims among the deletable candidates
+// with the paused policy: paused candidates are never drawn when skipped,
+// and drawn first, frozen anyway, when counted as victims.
+func pickPlanVictims(deletable []candidate, n int, opts *options, now time.Time) []candidate {
+	if opts.pausedPolicy != pausedVictim {
+		return pickPrioritizedVictims(deletable, n, opts.victimStrategy, opts.priorityLabel, now)
+	}
+	paused, others := splitPaused(deletable)
+	count := n
+	if count > len(paused) {
+		count = len(paused)
+	}
+	victims := pickVictims(paused, count, opts.victimStrategy, now)
+	return append(victims, pickPrioritizedVictims(others, n-count, opts.victimStrategy, opts.priorityLabel, now)...)
+}
+
+// stopVictim stops the victim. A paused victim does not get its stop signal
+// and would only be killed after its stop timeout: it is unpaused first, or
+// killed right away when paused victims are counted as such.
+func stopVictim(c candidate, opts *options) error {
+	if c.State == statePaused {
+		switch opts.pausedPolicy {
+		case pausedVictim:
+			return c.host.client.ContainerKill(context.Background(), c.ID, "SIGKILL")
+		case pausedUnpause:
+			// a conflict means it is not paused anymore.
+			if err := c.host.client.ContainerUnpause(context.Background(), c.ID); err != nil && !errdefs.IsConflict(err) {
+				return fmt.Errorf("could not unpause container id %s: %w", c.ID, err)
+			}
+		}
+	}
+	return stopContainer(c.host, c.ID, opts)
+}
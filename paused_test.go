@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+)
+
+func TestPickPlanVictims(t *testing.T) {
+	now := time.Now()
+	candidates := []candidate{}
+	for id, state := range map[string]string{"p1": statePaused, "p2": statePaused, "r1": stateRunning, "r2": stateRunning, "r3": stateRunning} {
+		candidates = append(candidates, candidate{Container: types.Container{ID: id, State: state, Created: now.Unix()}})
+	}
+	for _, tc := range []struct {
+		policy string
+		n      int
+		states string
+	}{
+		{pausedVictim, 1, "p"},
+		{pausedVictim, 2, "pp"},
+		{pausedVictim, 4, "pprr"},
+		{pausedUnpause, 5, "pprrr"},
+	} {
+		victims := pickPlanVictims(candidates, tc.n, &options{pausedPolicy: tc.policy, victimStrategy: victimsUniform}, now)
+		states := []string{}
+		for _, v := range victims {
+			states = append(states, v.ID[:1])
+		}
+		sort.Strings(states)
+		if got := strings.Join(states, ""); got != tc.states {
+			t.Errorf("%s: %v victims %q, expected %q", tc.policy, tc.n, got, tc.states)
+		}
+	}
+}
+
+func TestMakePlanSkipsPaused(t *testing.T) {
+	h := &host{name: "local"}
+	candidates := []candidate{
+		{Container: types.Container{ID: "p1", State: statePaused}, host: h},
+		{Container: types.Container{ID: "r1", State: stateRunning}, host: h},
+	}
+	opts := &options{mode: modeDown, pausedPolicy: pausedSkip, victimStrategy: victimsUniform}
+	for i := 0; i < 10; i++ {
+		p, err := makePlan(target{image: "redis"}, candidates, nil, RatioValue{Up: 0, Down: 1}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.victims) != 1 || p.victims[0].ID != "r1" {
+			t.Fatalf("victims %v, expected r1 only", p.victims)
+		}
+	}
+	if _, err := makePlan(target{image: "redis"}, candidates, nil, RatioValue{Up: 0, Down: 2}, opts); err == nil {
+		t.Error("paused candidate deleted with the skip policy")
+	}
+}
+
+func TestStopVictim(t *testing.T) {
+	tests := []struct {
+		policy  string
+		state   string
+		unpause error
+		want    string
+		err     bool
+	}{
+		{pausedUnpause, stateRunning, nil, "stop victim", false},
+		{pausedUnpause, statePaused, nil, "unpause victim, stop victim", false},
+		{pausedUnpause, statePaused, errdefs.Conflict(errors.New("container is not paused")), "unpause victim, stop victim", false},
+		{pausedUnpause, statePaused, errors.New("cannot unpause"), "unpause victim", true},
+		{pausedVictim, statePaused, nil, "kill SIGKILL victim", false},
+		{pausedVictim, stateRunning, nil, "stop victim", false},
+	}
+	for _, test := range tests {
+		m := &mockClient{UnpauseFunc: func(id string) error { return test.unpause }}
+		c := candidate{Container: types.Container{ID: "victim", State: test.state}, host: mockHost(m)}
+		err := stopVictim(c, &options{pausedPolicy: test.policy})
+		if (err != nil) != test.err {
+			t.Errorf("%s %s: stopVictim = %v", test.policy, test.state, err)
+		}
+		if got := m.Calls(); got != test.want {
+			t.Errorf("%s %s: calls = %q, want %q", test.policy, test.state, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// constraint is a swarm style placement constraint, e.g. node.labels.zone==a.
+type constraint struct {
+	key   string
+	equal bool
+	value string
+}
+
+func parseConstraint(s string) (constraint, error) {
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(s, op); i > 0 {
+			return constraint{
+				key:   strings.TrimSpace(s[:i]),
+				equal: op == "==",
+				value: strings.TrimSpace(s[i+len(op):]),
+			}, nil
+		}
+	}
+	return constraint{}, fmt.Errorf("invalid constraint %q, expected key==value or key!=value", s)
+}
+
+func (c constraint) match(attributes map[string]string) bool {
+	value, ok := attributes[c.key]
+	return ok && value == c.value == c.equal || !ok && !c.equal
+}
+
+func (c constraint) String() string {
+	if c.equal {
+		return c.key + "==" + c.value
+	}
+	return c.key + "!=" + c.value
+}
+
+// nodeAttributes describes the node of the docker daemon with the keys
+// usable in constraints: node.id, node.hostname, node.role,
+// node.platform.os, node.platform.arch, node.labels.* and engine.labels.*.
+// Node labels and role are only known when the daemon is a swarm manager.
+func nodeAttributes(client dockerClient) (map[string]string, error) {
+	info, err := client.Info(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not get docker info: %w", err)
+	}
+	attributes := map[string]string{
+		"node.hostname":      info.Name,
+		"node.platform.os":   info.OSType,
+		"node.platform.arch": info.Architecture,
+	}
+	for _, label := range info.Labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) == 2 {
+			attributes["engine.labels."+kv[0]] = kv[1]
+		}
+	}
+	if info.Swarm.NodeID == "" {
+		return attributes, nil
+	}
+	attributes["node.id"] = info.Swarm.NodeID
+	if !info.Swarm.ControlAvailable {
+		return attributes, nil
+	}
+	node, _, err := client.NodeInspectWithRaw(context.Background(), info.Swarm.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect swarm node %s: %w", info.Swarm.NodeID, err)
+	}
+	attributes["node.role"] = string(node.Spec.Role)
+	for key, value := range node.Spec.Labels {
+		attributes["node.labels."+key] = value
+	}
+	return attributes, nil
+}
+
+// unmetConstraints returns the constraints the node of the daemon does not
+// satisfy, copies must not be created there when there are some.
+func unmetConstraints(client dockerClient, constraints []string) ([]string, error) {
+	if len(constraints) == 0 {
+		return nil, nil
+	}
+	attributes, err := nodeAttributes(client)
+	if err != nil {
+		return nil, err
+	}
+	unmet := []string{}
+	for _, s := range constraints {
+		c, err := parseConstraint(s)
+		if err != nil {
+			return nil, err
+		}
+		if !c.match(attributes) {
+			unmet = append(unmet, c.String())
+		}
+	}
+	return unmet, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// fetchPlatform fetches the operating system and architecture of the
+// daemon, and whether it runs rootless, once.
+func (h *host) fetchPlatform() error {
+	if h.ostype != "" {
+		return nil
+	}
+	info, err := h.client.Info(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not get docker info of host %s: %w", h.name, err)
+	}
+	h.ostype, h.arch = info.OSType, normalizeArch(info.Architecture)
+	h.rootless, h.cgroupVersion = rootlessSecurityOptions(info.SecurityOptions), info.CgroupVersion
+	return nil
+}
+
+// normalizeArch maps the architectures reported by daemons, uname style, to
+// the image ones.
+func normalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv7l", "armv6l", "armhf":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	}
+	return arch
+}
+
+// checkPlatform makes sure the image of copies runs natively on the host:
+// when the image the host has is built for another platform, the variant of
+// the host platform is pulled from its multi-arch manifest if any, the copies
+// are refused otherwise instead of crash looping under emulation.
+func checkPlatform(target *host, image string, opts *options) error {
+	if err := target.fetchPlatform(); err != nil {
+		return err
+	}
+	inspect, _, err := target.client.ImageInspectWithRaw(context.Background(), image)
+	if client.IsErrNotFound(err) {
+		// the daemon pulls the variant of its platform on create.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not inspect image %s on host %s: %w", image, target.name, err)
+	}
+	if inspect.Os == target.ostype && normalizeArch(inspect.Architecture) == target.arch {
+		return nil
+	}
+	mismatch := fmt.Errorf("image %s is built for %s/%s, host %s is %s/%s", image, inspect.Os, inspect.Architecture, target.name, target.ostype, target.arch)
+	auth, err := encodedAuth(image, opts)
+	if err != nil {
+		return err
+	}
+	distribution, err := target.client.DistributionInspect(context.Background(), image, auth)
+	if err != nil {
+		logrus.WithError(err).WithField("image", image).Debug("could not get image platforms")
+		return mismatch
+	}
+	for _, platform := range distribution.Platforms {
+		if platform.OS != target.ostype || platform.Architecture != target.arch {
+			continue
+		}
+		variant := platform.OS + "/" + platform.Architecture
+		if platform.Variant != "" {
+			variant += "/" + platform.Variant
+		}
+		logrus.WithField("image", image).WithField("host", target.name).WithField("platform", variant).Info("select image platform")
+		return pullImage(target, image, variant, opts)
+	}
+	return mismatch
+}
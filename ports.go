@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// portRange is a range of host ports, both included. The zero range lets
+// the daemon choose the ports.
+type portRange struct {
+	first, last int
+}
+
+func parsePortRange(s string) (portRange, error) {
+	if s == "" {
+		return portRange{}, nil
+	}
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return portRange{}, fmt.Errorf("invalid host port range %q, expected first-last", s)
+	}
+	first, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid host port range %q: %w", s, err)
+	}
+	last, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid host port range %q: %w", s, err)
+	}
+	if first <= 0 || last > 65535 || first > last {
+		return portRange{}, fmt.Errorf("invalid host port range %q, expected 1 <= first <= last <= 65535", s)
+	}
+	return portRange{first: first, last: last}, nil
+}
+
+// portAllocator hands out the host ports of a range to the copies of each
+// host, and takes them back when the copies are removed.
+type portAllocator struct {
+	mu sync.Mutex
+	// used are the ports allocated on each host, by host name, with the id
+	// of their container, empty until it is created.
+	used map[string]map[int]string
+}
+
+var hostPorts = &portAllocator{used: map[string]map[int]string{}}
+
+// prepare publishes the ports of the copy on ports of the range, free on the
+// host, or on ports chosen by the daemon without range, instead of the host
+// ports of its source, which are taken. It returns the allocated ports. The
+// privileged ports of the range are skipped on rootless daemons.
+func (a *portAllocator) prepare(h *host, spec *copySpec, r portRange) ([]int, error) {
+	if spec.HostConfig == nil || len(spec.HostConfig.PortBindings) == 0 {
+		return nil, nil
+	}
+	ports := make([]string, 0, len(spec.HostConfig.PortBindings))
+	for port := range spec.HostConfig.PortBindings {
+		ports = append(ports, string(port))
+	}
+	sort.Strings(ports)
+	if r.first > 0 && r.first < privilegedPorts && h.isRootless() {
+		r.first = privilegedPorts
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	allocated := []int{}
+	bindings := nat.PortMap{}
+	for _, port := range ports {
+		for _, binding := range spec.HostConfig.PortBindings[nat.Port(port)] {
+			binding.HostPort = ""
+			if r.first > 0 {
+				p, ok := a.allocate(h.name, r)
+				if !ok {
+					a.free(h.name, allocated)
+					return nil, fmt.Errorf("no host port left in range %v-%v on host %s", r.first, r.last, h.name)
+				}
+				allocated = append(allocated, p)
+				binding.HostPort = strconv.Itoa(p)
+			}
+			bindings[nat.Port(port)] = append(bindings[nat.Port(port)], binding)
+		}
+	}
+	spec.HostConfig.PortBindings = bindings
+	return allocated, nil
+}
+
+// allocate returns the lowest port of the range free on the host.
+func (a *portAllocator) allocate(host string, r portRange) (int, bool) {
+	if a.used[host] == nil {
+		a.used[host] = map[int]string{}
+	}
+	for p := r.first; p <= r.last; p++ {
+		if _, ok := a.used[host][p]; !ok {
+			a.used[host][p] = ""
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+func (a *portAllocator) free(host string, ports []int) {
+	for _, p := range ports {
+		delete(a.used[host], p)
+	}
+}
+
+// assign allocates the ports to the container created with them.
+func (a *portAllocator) assign(h *host, ports []int, id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, p := range ports {
+		a.used[h.name][p] = id
+	}
+}
+
+// cancel takes back the ports of a container which could not be created.
+func (a *portAllocator) cancel(h *host, ports []int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.free(h.name, ports)
+}
+
+// release takes back the ports of a removed container.
+func (a *portAllocator) release(h *host, id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for p, owner := range a.used[h.name] {
+		if owner == id {
+			delete(a.used[h.name], p)
+		}
+	}
+}
+
+// snapshot returns the ports allocated to containers, by host name.
+func (a *portAllocator) snapshot() map[string]map[int]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	used := map[string]map[int]string{}
+	for host, ports := range a.used {
+		for p, id := range ports {
+			if id == "" {
+				continue
+			}
+			if used[host] == nil {
+				used[host] = map[int]string{}
+			}
+			used[host][p] = id
+		}
+	}
+	return used
+}
+
+// restore allocates the ports of a snapshot again.
+func (a *portAllocator) restore(used map[string]map[int]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for host, ports := range used {
+		if a.used[host] == nil {
+			a.used[host] = map[int]string{}
+		}
+		for p, id := range ports {
+			a.used[host][p] = id
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestParsePortRange(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want portRange
+		ok   bool
+	}{
+		{"", portRange{}, true},
+		{"30000-30999", portRange{30000, 30999}, true},
+		{"8080-8080", portRange{8080, 8080}, true},
+		{"30000", portRange{}, false},
+		{"2-1", portRange{}, false},
+		{"0-10", portRange{}, false},
+		{"1-70000", portRange{}, false},
+		{"a-b", portRange{}, false},
+	} {
+		got, err := parsePortRange(tc.s)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%q: got %v, %v, want %v", tc.s, got, err, tc.want)
+		}
+	}
+}
+
+func portSpec() copySpec {
+	return copySpec{HostConfig: &ac.HostConfig{PortBindings: nat.PortMap{
+		"80/tcp":  {{HostIP: "127.0.0.1", HostPort: "8080"}},
+		"443/tcp": {{HostPort: "8443"}},
+	}}}
+}
+
+func TestPortAllocator(t *testing.T) {
+	a := &portAllocator{used: map[string]map[int]string{}}
+	h, other := &host{name: "a"}, &host{name: "b"}
+
+	spec := portSpec()
+	ports, err := a.prepare(h, &spec, portRange{})
+	if err != nil || len(ports) != 0 {
+		t.Fatalf("without range, got ports %v, %v", ports, err)
+	}
+	if got := spec.HostConfig.PortBindings["80/tcp"][0]; got.HostPort != "" || got.HostIP != "127.0.0.1" {
+		t.Errorf("without range, got binding %v, want the daemon to choose the port on the same ip", got)
+	}
+
+	r := portRange{30000, 30002}
+	first := portSpec()
+	ports, err = a.prepare(h, &first, r)
+	if err != nil || !reflect.DeepEqual(ports, []int{30000, 30001}) {
+		t.Fatalf("got ports %v, %v, want 30000 and 30001", ports, err)
+	}
+	if got := first.HostConfig.PortBindings["80/tcp"][0].HostPort; got != "30001" {
+		t.Errorf("got host port %s for 80, want 30001", got)
+	}
+	a.assign(h, ports, "first")
+	second := portSpec()
+	if _, err := a.prepare(h, &second, r); err == nil {
+		t.Error("allocated more ports than the range has")
+	}
+	if _, err := a.prepare(other, &second, r); err != nil {
+		t.Errorf("ports of another host taken: %v", err)
+	}
+	if got := a.snapshot(); !reflect.DeepEqual(got, map[string]map[int]string{"a": {30000: "first", 30001: "first"}}) {
+		t.Errorf("got snapshot %v", got)
+	}
+	a.release(h, "first")
+	third := portSpec()
+	if ports, err := a.prepare(h, &third, r); err != nil || !reflect.DeepEqual(ports, []int{30000, 30001}) {
+		t.Errorf("released ports not allocated again, got %v, %v", ports, err)
+	}
+}
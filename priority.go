@@ -0,0 +1,44 @@
+package main
+
+import "time"
+
+const (
+	priorityLow    = "low"
+	priorityNormal = "normal"
+	priorityHigh   = "high"
+)
+
+// priorityClasses are the priority classes in the order their containers
+// are picked as victims.
+var priorityClasses = []string{priorityLow, priorityNormal, priorityHigh}
+
+// priorityClass returns the priority class of the container from its
+// priority label, normal when it has none or an unknown one.
+func priorityClass(c candidate, label string) string {
+	switch value := c.Labels[label]; value {
+	case priorityLow, priorityHigh:
+		return value
+	}
+	return priorityNormal
+}
+
+// pickPrioritizedVictims draws n distinct victims among candidates like
+// pickVictims, class after class: low priority containers are preferred and
+// high priority ones are only drawn when no other candidate is left, like the
+// eviction policies of schedulers.
+func pickPrioritizedVictims(candidates []candidate, n int, strategy, label string, now time.Time) []candidate {
+	classes := map[string][]candidate{}
+	for _, c := range candidates {
+		class := priorityClass(c, label)
+		classes[class] = append(classes[class], c)
+	}
+	victims := []candidate{}
+	for _, class := range priorityClasses {
+		count := n - len(victims)
+		if count > len(classes[class]) {
+			count = len(classes[class])
+		}
+		victims = append(victims, pickVictims(classes[class], count, strategy, now)...)
+	}
+	return victims
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestPickPrioritizedVictims(t *testing.T) {
+	now := time.Now()
+	candidates := []candidate{}
+	for id, priority := range map[string]string{"h1": "high", "h2": "high", "n1": "", "n2": "normal", "n3": "unknown", "l1": "low", "l2": "low"} {
+		candidates = append(candidates, candidate{Container: types.Container{ID: id, Labels: map[string]string{"priority": priority}, Created: now.Unix()}})
+	}
+	for _, tc := range []struct {
+		n       int
+		classes string
+	}{
+		{0, ""},
+		{1, "l"},
+		{2, "ll"},
+		{3, "lln"},
+		{5, "llnnn"},
+		{6, "hllnnn"},
+		{7, "hhllnnn"},
+	} {
+		for _, strategy := range []string{victimsUniform, victimsAge} {
+			victims := pickPrioritizedVictims(candidates, tc.n, strategy, "priority", now)
+			classes := []string{}
+			for _, v := range victims {
+				classes = append(classes, v.ID[:1])
+			}
+			sort.Strings(classes)
+			if got := strings.Join(classes, ""); got != tc.classes {
+				t.Errorf("%s: %v victims of classes %q, expected %q", strategy, tc.n, got, tc.classes)
+			}
+		}
+	}
+}
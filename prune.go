@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// pruneImages removes the dangling images bubble created on every host.
+func pruneImages(hosts []*host) {
+	args := filters.NewArgs(
+		filters.Arg("dangling", "true"),
+		filters.Arg("label", managedLabel),
+	)
+	for _, h := range hosts {
+		report, err := h.client.ImagesPrune(context.Background(), args)
+		if err != nil {
+			logrus.WithError(err).WithField("host", h.name).Error("could not prune images")
+			continue
+		}
+		logrus.WithField("host", h.name).
+			WithField("images", len(report.ImagesDeleted)).
+			WithField("reclaimed", report.SpaceReclaimed).
+			Info("prune images")
+	}
+}
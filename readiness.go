@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
+)
+
+// readyPollInterval is the delay between two readiness attempts.
+const readyPollInterval = time.Second
+
+// waitReady waits until the new container passes the readiness checks
+// configured, or until the ready timeout.
+func waitReady(log *logrus.Entry, target *host, id string, opts *options) error {
+	if opts.readyPort == 0 && opts.readyLog == "" && opts.readyCmd == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.readyTimeout)
+	defer cancel()
+	if opts.readyPort != 0 {
+		if err := waitPort(ctx, target, id, opts.readyPort, opts.readyProbeImage); err != nil {
+			return err
+		}
+	}
+	if opts.readyLog != "" {
+		if err := waitLog(ctx, target, id, opts.readyLog); err != nil {
+			return err
+		}
+	}
+	if opts.readyCmd != "" {
+		if err := waitCmd(ctx, target, id, opts.readyCmd); err != nil {
+			return err
+		}
+	}
+	log.WithField("container", id).WithField("host", target.name).Info("container ready")
+	return nil
+}
+
+// waitPort waits until the port accepts tcp connections. With a probe image,
+// the port is checked from a probe container sharing the network namespace
+// of the container, which works whatever the host and wherever bubble runs.
+// Otherwise it is dialed on the host addresses it is published on, then on
+// the addresses of the container, only reachable when bubble runs on the
+// host.
+func waitPort(ctx context.Context, target *host, id string, port int, probeImage string) error {
+	for {
+		if probeImage != "" {
+			ok, err := probePort(ctx, target, id, port, probeImage)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+		} else {
+			infos, err := target.client.ContainerInspect(ctx, id)
+			if err != nil {
+				return fmt.Errorf("could not inspect container id %s: %w", id, err)
+			}
+			addresses := append(publishedAddresses(target, infos, port), containerAddresses(infos, port)...)
+			for _, address := range addresses {
+				dialer := net.Dialer{Timeout: readyPollInterval}
+				conn, err := dialer.DialContext(ctx, "tcp", address)
+				if err == nil {
+					conn.Close()
+					return nil
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("port %v of container id %s not ready: %w", port, id, ctx.Err())
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// waitLog follows the logs of the container until a line contains text.
+func waitLog(ctx context.Context, target *host, id, text string) error {
+	infos, err := target.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not inspect container id %s: %w", id, err)
+	}
+	logs, err := target.client.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not get logs of container id %s: %w", id, err)
+	}
+	defer logs.Close()
+
+	var r io.Reader = logs
+	if infos.Config == nil || !infos.Config.Tty {
+		r = demuxLogs(logs)
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), text) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("log line %q of container id %s not found: %w", text, id, ctx.Err())
+	}
+	return fmt.Errorf("logs of container id %s ended without line %q", id, text)
+}
+
+// demuxLogs strips the 8 bytes frame headers docker puts in front of each
+// chunk of logs of containers without tty to tell stdout and stderr apart.
+func demuxLogs(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		header := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(r, header); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			size := int64(binary.BigEndian.Uint32(header[4:]))
+			if _, err := io.CopyN(pw, r, size); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// waitCmd runs the command in the container shell until it exits with 0.
+func waitCmd(ctx context.Context, target *host, id, cmd string) error {
+	for {
+		code, err := execCmd(ctx, target, id, cmd)
+		if err != nil {
+			return err
+		}
+		if code == 0 {
+			return nil
+		}
+		logrus.WithField("container", id).WithField("exit_code", code).Debug("ready command failed")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ready command of container id %s did not succeed: %w", id, ctx.Err())
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// execCmd runs the command detached in the container and polls it until it
+// exits, returning its exit code.
+func execCmd(ctx context.Context, target *host, id, cmd string) (int, error) {
+	exec, err := target.client.ContainerExecCreate(ctx, id, types.ExecConfig{
+		Cmd:    shellCmd(target, cmd),
+		Detach: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not create exec in container id %s: %w", id, err)
+	}
+	if err := target.client.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		return 0, fmt.Errorf("could not start exec in container id %s: %w", id, err)
+	}
+	for {
+		inspect, err := target.client.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return 0, fmt.Errorf("could not inspect exec in container id %s: %w", id, err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("ready command of container id %s did not finish: %w", id, ctx.Err())
+		case <-time.After(readyPollInterval / 10):
+		}
+	}
+}
+
+// containerAddresses returns the addresses of the port on the networks of
+// the container.
+func containerAddresses(infos types.ContainerJSON, port int) []string {
+	addresses := []string{}
+	if infos.NetworkSettings == nil {
+		return addresses
+	}
+	for _, endpoint := range infos.NetworkSettings.Networks {
+		if endpoint.IPAddress != "" {
+			addresses = append(addresses, net.JoinHostPort(endpoint.IPAddress, strconv.Itoa(port)))
+		}
+		if endpoint.GlobalIPv6Address != "" {
+			addresses = append(addresses, net.JoinHostPort(endpoint.GlobalIPv6Address, strconv.Itoa(port)))
+		}
+	}
+	return addresses
+}
+
+// publishedAddresses returns the addresses the port of the container is
+// published on, on the docker host.
+func publishedAddresses(target *host, infos types.ContainerJSON, port int) []string {
+	addresses := []string{}
+	if infos.NetworkSettings == nil {
+		return addresses
+	}
+	for _, binding := range infos.NetworkSettings.Ports[nat.Port(strconv.Itoa(port)+"/tcp")] {
+		ip := binding.HostIP
+		if ip == "" || ip == "0.0.0.0" || ip == "::" {
+			ip = daemonAddress(target)
+		}
+		addresses = append(addresses, net.JoinHostPort(ip, binding.HostPort))
+	}
+	return addresses
+}
+
+// daemonAddress returns the address of the docker host, the loopback one for
+// local sockets.
+func daemonAddress(target *host) string {
+	u, err := url.Parse(target.client.DaemonHost())
+	if err != nil || u.Hostname() == "" || u.Scheme == "unix" || u.Scheme == "npipe" {
+		return "127.0.0.1"
+	}
+	return u.Hostname()
+}
+
+// probePort runs a probe container in the network namespace of the
+// container, which checks whether the port accepts connections.
+func probePort(ctx context.Context, target *host, id string, port int, image string) (bool, error) {
+	created, err := target.client.ContainerCreate(ctx,
+		&ac.Config{Image: image, Cmd: []string{"nc", "-z", "127.0.0.1", strconv.Itoa(port)}, Labels: map[string]string{"bubble.probe": id}},
+		&ac.HostConfig{NetworkMode: ac.NetworkMode("container:" + id)},
+		nil, nil, "")
+	if err != nil {
+		return false, fmt.Errorf("could not create port probe of container id %s: %w", id, err)
+	}
+	defer func() {
+		if err := target.client.ContainerRemove(context.Background(), created.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logrus.WithError(err).WithField("container", created.ID).Warn("could not remove port probe")
+		}
+	}()
+	if err := target.client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return false, fmt.Errorf("could not start port probe of container id %s: %w", id, err)
+	}
+	statusCh, errCh := target.client.ContainerWait(ctx, created.ID, ac.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		return status.StatusCode == 0, nil
+	case err := <-errCh:
+		return false, fmt.Errorf("could not wait for port probe of container id %s: %w", id, err)
+	}
+}
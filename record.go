@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// interaction is a request of bubble to a daemon with the response of the
+// daemon, as recorded by --record and served back by --replay.
+type interaction struct {
+	Host    string      `json:"host"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Request []byte      `json:"request,omitempty"`
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+func (i interaction) key() string {
+	return i.Host + " " + i.Method + " " + i.Path
+}
+
+func checkRecording(o *options) error {
+	if o.record != "" && o.replay != "" {
+		return errors.New("--record can not be used with --replay")
+	}
+	return nil
+}
+
+// recording returns the client option recording or replaying the requests
+// of the client of the host, when asked to.
+func recording(opts *options, url string) (client.Opt, error) {
+	switch {
+	case opts.record != "":
+		r, err := openRecorder(opts.record)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *client.Client) error {
+			httpClient := c.HTTPClient()
+			httpClient.Transport = &recordingTransport{host: hostName(c, url), next: httpClient.Transport, recorder: r}
+			return client.WithHTTPClient(httpClient)(c)
+		}, nil
+	case opts.replay != "":
+		r, err := openReplayer(opts.replay)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *client.Client) error {
+			return client.WithHTTPClient(&http.Client{Transport: &replayTransport{host: hostName(c, url), replayer: r}})(c)
+		}, nil
+	}
+	return nil, nil
+}
+
+func hostName(c *client.Client, url string) string {
+	if url == "" {
+		return c.DaemonHost()
+	}
+	return url
+}
+
+// recorder appends the interactions to the record file, one json object
+// per line, as soon as their response is read, redacted since records are
+// meant to be shared.
+type recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+var (
+	recorders   = map[string]*recorder{}
+	recordersMu sync.Mutex
+)
+
+// openRecorder returns the recorder of the file, shared by the hosts.
+func openRecorder(path string) (*recorder, error) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	if r, ok := recorders[path]; ok {
+		return r, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create record file: %w", err)
+	}
+	r := &recorder{enc: json.NewEncoder(f)}
+	recorders[path] = r
+	return r, nil
+}
+
+func (r *recorder) record(i interaction) error {
+	i.Request = []byte(redaction.text(string(i.Request)))
+	i.Body = []byte(redaction.text(string(i.Body)))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(i)
+}
+
+type recordingTransport struct {
+	host     string
+	next     http.RoundTripper
+	recorder *recorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := interaction{Host: t.host, Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		i.Request = body
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	i.Status, i.Header = resp.StatusCode, resp.Header
+	resp.Body = &recordedBody{ReadCloser: resp.Body, interaction: i, recorder: t.recorder}
+	return resp, nil
+}
+
+// recordedBody records its interaction once its response is fully read or
+// closed, so that streamed responses are recorded up to where bubble read
+// them.
+type recordedBody struct {
+	io.ReadCloser
+	interaction interaction
+	recorder    *recorder
+	body        bytes.Buffer
+	once        sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.body.Write(p[:n])
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *recordedBody) done() {
+	b.once.Do(func() {
+		b.interaction.Body = b.body.Bytes()
+		if err := b.recorder.record(b.interaction); err != nil {
+			logrus.WithError(err).Error("could not record interaction")
+		}
+	})
+}
+
+// replayer serves the recorded responses back, the responses to the same
+// request of a host in the order they were recorded.
+type replayer struct {
+	mu        sync.Mutex
+	responses map[string][]interaction
+}
+
+var (
+	replayers   = map[string]*replayer{}
+	replayersMu sync.Mutex
+)
+
+// openReplayer returns the replayer of the file, shared by the hosts.
+func openReplayer(path string) (*replayer, error) {
+	replayersMu.Lock()
+	defer replayersMu.Unlock()
+	if r, ok := replayers[path]; ok {
+		return r, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open record file: %w", err)
+	}
+	defer f.Close()
+	r, err := readRecord(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read record file %s: %w", path, err)
+	}
+	replayers[path] = r
+	return r, nil
+}
+
+func readRecord(reader io.Reader) (*replayer, error) {
+	r := &replayer{responses: map[string][]interaction{}}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var i interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, err
+		}
+		r.responses[i.key()] = append(r.responses[i.key()], i)
+	}
+	return r, scanner.Err()
+}
+
+// next returns the next recorded response to the request, the last one
+// again once they were all served, e.g. for the pings.
+func (r *replayer) next(key string) (interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	responses := r.responses[key]
+	if len(responses) == 0 {
+		return interaction{}, false
+	}
+	if len(responses) > 1 {
+		r.responses[key] = responses[1:]
+	}
+	return responses[0], true
+}
+
+type replayTransport struct {
+	host     string
+	replayer *replayer
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	i, ok := t.replayer.next(interaction{Host: t.host, Method: req.Method, Path: req.URL.Path}.key())
+	if !ok {
+		return nil, fmt.Errorf("no recorded response to %s %s on host %s", req.Method, req.URL.Path, t.host)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}, nil
+}
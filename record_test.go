@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+func TestRecordReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.Write([]byte(`[{"Id":"first","Image":"redis"}]`))
+			return
+		}
+		w.Write([]byte(`[{"Id":"second","Image":"redis"}]`))
+	}))
+	defer server.Close()
+	url := "tcp://" + server.Listener.Addr().String()
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+
+	list := func(opts *options) []string {
+		opt, err := recording(opts, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := client.NewClientWithOpts(client.WithHost(url), client.WithVersion("1.41"), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for i := 0; i < 3; i++ {
+			containers, err := c.ContainerList(context.Background(), types.ContainerListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, containers[0].ID)
+		}
+		return ids
+	}
+	recorded := list(&options{record: path})
+	server.Close()
+	replayed := list(&options{replay: path})
+	want := []string{"first", "second", "second"}
+	for i := range want {
+		if recorded[i] != want[i] || replayed[i] != want[i] {
+			t.Errorf("got recorded %v and replayed %v, want %v", recorded, replayed, want)
+			break
+		}
+	}
+
+	opt, err := recording(&options{replay: path}, "tcp://elsewhere:2375")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.NewClientWithOpts(client.WithHost("tcp://elsewhere:2375"), client.WithVersion("1.41"), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ContainerList(context.Background(), types.ContainerListOptions{}); err == nil {
+		t.Error("replayed a response recorded on another host")
+	}
+}
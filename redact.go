@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redacted replaces the values of sensitive keys.
+const redacted = "[REDACTED]"
+
+// defaultRedactPatterns match the names of environment variables, labels
+// and other keys whose values are credentials.
+var defaultRedactPatterns = []string{`(?i)passw(or)?d`, `(?i)pwd`, `(?i)secret`, `(?i)token`, `(?i)api_?key`, `(?i)credential`, `(?i)private_?key`, `(?i)auth`}
+
+// assignment is a key=value pair in a text, like the environment variables
+// of a config.
+var assignment = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.-]*)=([^\s",]+)`)
+
+// redactor redacts the values of the keys matching its patterns.
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// redaction redacts the logs, the config diffs, the action stream and the
+// notifications, set up by the options.
+var redaction = &redactor{}
+
+func newRedactor(patterns []string) (*redactor, error) {
+	r := &redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *redactor) sensitive(key string) bool {
+	for _, re := range r.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// text redacts the values of the key=value pairs of s with a sensitive key.
+func (r *redactor) text(s string) string {
+	return assignment.ReplaceAllStringFunc(s, func(pair string) string {
+		m := assignment.FindStringSubmatch(pair)
+		if !r.sensitive(m[1]) {
+			return pair
+		}
+		return m[1] + "=" + redacted
+	})
+}
+
+// value redacts a json decoded value, the values of sensitive keys of its
+// objects and the key=value pairs of its strings.
+func (r *redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.text(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = r.value(item)
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if _, ok := item.(string); ok && r.sensitive(key) {
+				values[key] = redacted
+				continue
+			}
+			values[key] = r.value(item)
+		}
+		return values
+	}
+	return v
+}
+
+// err redacts the message of the error, returning the error itself when
+// there is nothing to redact so that it can still be inspected.
+func (r *redactor) err(err error) error {
+	if err == nil {
+		return nil
+	}
+	if s := r.text(err.Error()); s != err.Error() {
+		return errors.New(s)
+	}
+	return err
+}
+
+// Levels and Fire make the redactor a logrus hook redacting every log line.
+func (r *redactor) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (r *redactor) Fire(entry *logrus.Entry) error {
+	entry.Message = r.text(entry.Message)
+	data := make(logrus.Fields, len(entry.Data))
+	for key, v := range entry.Data {
+		switch v := v.(type) {
+		case error:
+			data[key] = r.err(v)
+		case string:
+			if r.sensitive(key) {
+				data[key] = redacted
+			} else {
+				data[key] = r.text(v)
+			}
+		default:
+			data[key] = r.value(v)
+		}
+	}
+	entry.Data = data
+	return nil
+}
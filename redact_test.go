@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := newRedactor(defaultRedactPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"DB_PASSWORD=hunter2":                  "DB_PASSWORD=" + redacted,
+		"PATH=/bin GITHUB_TOKEN=ghp_x LANG=C":  "PATH=/bin GITHUB_TOKEN=" + redacted + " LANG=C",
+		"could not pull: API_KEY=abc, retry":   "could not pull: API_KEY=" + redacted + ", retry",
+		"create container redis-bubble-1a2b3c": "create container redis-bubble-1a2b3c",
+	} {
+		if got := r.text(in); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+	got := r.value(map[string]interface{}{
+		"Env":    []interface{}{"AWS_SECRET_ACCESS_KEY=xyz", "HOME=/root"},
+		"Labels": map[string]interface{}{"auth": "basic", "team": "payments"},
+	})
+	want := map[string]interface{}{
+		"Env":    []interface{}{"AWS_SECRET_ACCESS_KEY=" + redacted, "HOME=/root"},
+		"Labels": map[string]interface{}{"auth": redacted, "team": "payments"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	entry := logrus.WithError(errors.New("bad REDIS_PASSWORD=x")).WithField("token", "t0k3n")
+	entry.Message = "env SECRET=s"
+	if err := r.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "env SECRET="+redacted || entry.Data["token"] != redacted || entry.Data[logrus.ErrorKey].(error).Error() != "bad REDIS_PASSWORD="+redacted {
+		t.Errorf("got entry %q %v", entry.Message, entry.Data)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errRedisNil is the nil reply of redis.
+var errRedisNil = errors.New("redis nil reply")
+
+// redisClient is a minimal redis client speaking the resp protocol over a
+// single connection, reconnected after any failure.
+type redisClient struct {
+	addr     string
+	password string
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+func newRedisClient(addr, password string) *redisClient {
+	return &redisClient{addr: addr, password: password}
+}
+
+func (c *redisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("could not connect to redis %s: %w", c.addr, err)
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+	if c.password != "" {
+		if _, err := c.send("AUTH", c.password); err != nil {
+			c.Close()
+			return fmt.Errorf("could not authenticate to redis %s: %w", c.addr, err)
+		}
+	}
+	return nil
+}
+
+// do sends the command and returns its reply: a string, an int64, a slice
+// of replies, or errRedisNil.
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.send(args...)
+	var redisErr redisError
+	if err != nil && err != errRedisNil && !errors.As(err, &redisErr) {
+		c.Close()
+	}
+	return reply, err
+}
+
+func (c *redisClient) send(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	cmd := &strings.Builder{}
+	fmt.Fprintf(cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, cmd.String()); err != nil {
+		return nil, fmt.Errorf("could not send redis %s: %w", args[0], err)
+	}
+	return c.read()
+}
+
+// redisError is an error reply of redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func (c *redisClient) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("could not read redis reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, fmt.Errorf("could not read redis reply: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis array length %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := c.read()
+			if err != nil && err != errRedisNil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown redis reply %q", line)
+}
+
+func (c *redisClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+	return err
+}
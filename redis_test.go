@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedisRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  interface{}
+		err   error
+	}{
+		{"simple string", "+OK\r\n", "OK", nil},
+		{"error", "-ERR wrong\r\n", nil, redisError("ERR wrong")},
+		{"integer", ":42\r\n", int64(42), nil},
+		{"bulk string", "$5\r\nhello\r\n", "hello", nil},
+		{"bulk string with crlf", "$4\r\na\r\nb\r\n", "a\r\nb", nil},
+		{"empty bulk string", "$0\r\n\r\n", "", nil},
+		{"nil bulk string", "$-1\r\n", nil, errRedisNil},
+		{"array", "*3\r\n$3\r\nkey\r\n:1\r\n$-1\r\n", []interface{}{"key", int64(1), nil}, nil},
+		{"nested array", "*2\r\n*1\r\n+a\r\n*0\r\n", []interface{}{[]interface{}{"a"}, []interface{}{}}, nil},
+		{"nil array", "*-1\r\n", nil, errRedisNil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &redisClient{reader: bufio.NewReader(strings.NewReader(tt.reply))}
+			got, err := c.read()
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+	for _, reply := range []string{"", "\r\n", "?\r\n", "$x\r\n", "$5\r\nab\r\n"} {
+		c := &redisClient{reader: bufio.NewReader(strings.NewReader(reply))}
+		if _, err := c.read(); err == nil {
+			t.Errorf("reply %q: expected an error", reply)
+		}
+	}
+}
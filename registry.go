@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// dockerHubServer is the key of docker hub credentials in docker config.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of ~/.docker/config.json holding credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+func defaultDockerConfig() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// registryOf returns the registry domain of an image and the server
+// address its credentials are stored under.
+func registryOf(image string) (string, string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid image %s: %w", image, err)
+	}
+	domain := reference.Domain(named)
+	if domain == "docker.io" {
+		return domain, dockerHubServer, nil
+	}
+	return domain, domain, nil
+}
+
+// registryAuth resolves the credentials of the image registry, from the
+// --registry-auth flags first, then from the docker config file and its
+// credential helpers. It returns an empty auth for anonymous pulls.
+func registryAuth(image string, opts *options) (types.AuthConfig, error) {
+	domain, server, err := registryOf(image)
+	if err != nil {
+		return types.AuthConfig{}, err
+	}
+	for _, auth := range opts.registryAuth {
+		registry, credentials := "", auth
+		if i := strings.Index(auth, "="); i >= 0 {
+			registry, credentials = auth[:i], auth[i+1:]
+		}
+		if registry != "" && registry != domain {
+			continue
+		}
+		kv := strings.SplitN(credentials, ":", 2)
+		if len(kv) != 2 {
+			return types.AuthConfig{}, fmt.Errorf("invalid registry auth, expected [registry=]user:password")
+		}
+		return types.AuthConfig{Username: kv[0], Password: kv[1], ServerAddress: server}, nil
+	}
+	if opts.dockerConfig == "" {
+		return types.AuthConfig{}, nil
+	}
+	data, err := ioutil.ReadFile(opts.dockerConfig)
+	if os.IsNotExist(err) {
+		return types.AuthConfig{}, nil
+	}
+	if err != nil {
+		return types.AuthConfig{}, fmt.Errorf("could not read docker config: %w", err)
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return types.AuthConfig{}, fmt.Errorf("could not decode docker config %s: %w", opts.dockerConfig, err)
+	}
+	if helper, ok := config.CredHelpers[domain]; ok {
+		return credentialHelper(helper, server)
+	}
+	if auth, ok := config.Auths[server]; ok {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return types.AuthConfig{}, fmt.Errorf("invalid auth of %s in docker config: %w", server, err)
+		}
+		kv := strings.SplitN(string(decoded), ":", 2)
+		if len(kv) != 2 {
+			return types.AuthConfig{IdentityToken: auth.IdentityToken, ServerAddress: server}, nil
+		}
+		return types.AuthConfig{Username: kv[0], Password: kv[1], IdentityToken: auth.IdentityToken, ServerAddress: server}, nil
+	}
+	if config.CredsStore != "" {
+		return credentialHelper(config.CredsStore, server)
+	}
+	return types.AuthConfig{}, nil
+}
+
+// credentialHelper gets the credentials of the server from a docker
+// credential helper program.
+func credentialHelper(helper, server string) (types.AuthConfig, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(string(out), "credentials not found") {
+			return types.AuthConfig{}, nil
+		}
+		return types.AuthConfig{}, fmt.Errorf("credential helper %s failed: %w: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	var credentials struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &credentials); err != nil {
+		return types.AuthConfig{}, fmt.Errorf("could not decode credential helper %s output: %w", helper, err)
+	}
+	if credentials.Username == "<token>" {
+		return types.AuthConfig{IdentityToken: credentials.Secret, ServerAddress: server}, nil
+	}
+	return types.AuthConfig{Username: credentials.Username, Password: credentials.Secret, ServerAddress: server}, nil
+}
+
+// encodedAuth returns the registry credentials of the image encoded for the
+// docker api.
+func encodedAuth(image string, opts *options) (string, error) {
+	auth, err := registryAuth(image, opts)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+// pullImage pulls the image on the host with the registry credentials, for
+// the platform, os/arch[/variant], or the host one when empty.
+func pullImage(target *host, image, platform string, opts *options) error {
+	auth, err := encodedAuth(image, opts)
+	if err != nil {
+		return err
+	}
+	resp, err := target.client.ImagePull(context.Background(), image, types.ImagePullOptions{
+		RegistryAuth: auth,
+		Platform:     platform,
+	})
+	if err != nil {
+		return fmt.Errorf("could not pull image %s on host %s: %w", image, target.name, err)
+	}
+	defer resp.Close()
+	decoder := json.NewDecoder(resp)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("could not read pull of image %s: %w", image, err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("could not pull image %s on host %s: %s", image, target.name, message.Error)
+		}
+	}
+	logrus.WithField("image", image).WithField("host", target.name).Info("pull image")
+	return nil
+}
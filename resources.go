@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// resourceSample aggregates the resource usage of all the containers of the
+// targets at one point of the run.
+type resourceSample struct {
+	at         time.Time
+	containers int
+	cpuPercent float64
+	memory     uint64
+	rxBytes    uint64
+	txBytes    uint64
+}
+
+// sampleResources reads the stats of every container of the targets.
+func sampleResources(hosts []*host, targets []target) (resourceSample, error) {
+	sample := resourceSample{at: time.Now()}
+	for _, t := range targets {
+		candidates, err := listCandidates(hosts, t, []string{stateRunning})
+		if err != nil {
+			return sample, err
+		}
+		for _, c := range candidates {
+			s, err := containerStats(c.host.client, c.ID)
+			if err != nil {
+				logrus.WithError(err).WithField("container", c.ID).Debug("could not get container stats")
+				continue
+			}
+			sample.containers++
+			sample.cpuPercent += cpuPercent(s)
+			sample.memory += memoryUsage(s)
+			for _, n := range s.Networks {
+				sample.rxBytes += n.RxBytes
+				sample.txBytes += n.TxBytes
+			}
+		}
+	}
+	return sample, nil
+}
+
+func containerStats(client dockerClient, id string) (types.StatsJSON, error) {
+	var s types.StatsJSON
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return s, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return s, fmt.Errorf("could not decode stats: %w", err)
+	}
+	return s, nil
+}
+
+// cpuPercent computes the cpu usage like docker stats does, 100% being one
+// full cpu.
+func cpuPercent(s types.StatsJSON) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage is the memory used without the page cache, like docker stats.
+func memoryUsage(s types.StatsJSON) uint64 {
+	cache := s.MemoryStats.Stats["cache"]
+	if inactive, ok := s.MemoryStats.Stats["inactive_file"]; ok {
+		cache = inactive
+	}
+	if cache > s.MemoryStats.Usage {
+		return 0
+	}
+	return s.MemoryStats.Usage - cache
+}
+
+// maxResourceSamples is how many of the last samples are kept to be printed,
+// runs without duration going on indefinitely.
+const maxResourceSamples = 120
+
+// resourceSeries is the time series of the samples of a run: its last
+// samples and the aggregates of all of them.
+type resourceSeries struct {
+	samples   []resourceSample
+	count     int
+	cpuSum    float64
+	cpuMax    float64
+	memorySum float64
+	memoryMax uint64
+}
+
+func (rs *resourceSeries) add(sample resourceSample) {
+	if len(rs.samples) == maxResourceSamples {
+		copy(rs.samples, rs.samples[1:])
+		rs.samples = rs.samples[:len(rs.samples)-1]
+	}
+	rs.samples = append(rs.samples, sample)
+	rs.count++
+	rs.cpuSum += sample.cpuPercent
+	rs.memorySum += mib(sample.memory)
+	if sample.cpuPercent > rs.cpuMax {
+		rs.cpuMax = sample.cpuPercent
+	}
+	if sample.memory > rs.memoryMax {
+		rs.memoryMax = sample.memory
+	}
+}
+
+func (r *runner) sampleResources() {
+	sample, err := sampleResources(r.hosts, r.opts.targets)
+	if err != nil {
+		logrus.WithError(err).Error("could not sample resources")
+		return
+	}
+	r.resources.add(sample)
+	logrus.WithField("containers", sample.containers).
+		WithField("cpu_percent", sample.cpuPercent).
+		WithField("memory", sample.memory).
+		Debug("sample resources")
+}
+
+func (r *runner) printResources() {
+	rs := r.resources
+	if rs.count == 0 {
+		return
+	}
+	if rs.count > len(rs.samples) {
+		fmt.Fprintf(r.report(), "last %v of %v samples\n", len(rs.samples), rs.count)
+	}
+	fmt.Fprintf(r.report(), "%-20s %10s %10s %12s %12s %12s\n", "time", "containers", "cpu %", "memory MiB", "rx MiB", "tx MiB")
+	for _, s := range rs.samples {
+		fmt.Fprintf(r.report(), "%-20s %10d %10.1f %12.1f %12.1f %12.1f\n",
+			s.at.Format("2006-01-02T15:04:05"), s.containers, s.cpuPercent, mib(s.memory), mib(s.rxBytes), mib(s.txBytes))
+	}
+	n := float64(rs.count)
+	fmt.Fprintf(r.report(), "average cpu: %.1f%%, average memory: %.1f MiB over %v samples\n", rs.cpuSum/n, rs.memorySum/n, rs.count)
+	fmt.Fprintf(r.report(), "peak cpu: %.1f%%, peak memory: %.1f MiB\n", rs.cpuMax, mib(rs.memoryMax))
+}
+
+func mib(bytes uint64) float64 {
+	return float64(bytes) / (1 << 20)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResourceSeries(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		kept     int
+		first    int
+		cpuMean  float64
+		cpuMax   float64
+		memoryMB float64
+	}{
+		{1, 1, 0, 0, 0, 0},
+		{10, 10, 0, 4.5, 9, 4.5},
+		{maxResourceSamples + 30, maxResourceSamples, 30, float64(maxResourceSamples+29) / 2, maxResourceSamples + 29, float64(maxResourceSamples+29) / 2},
+	} {
+		var rs resourceSeries
+		start := time.Now()
+		for i := 0; i < tc.n; i++ {
+			rs.add(resourceSample{at: start.Add(time.Duration(i) * time.Second), cpuPercent: float64(i), memory: uint64(i) << 20})
+		}
+		if len(rs.samples) != tc.kept || rs.count != tc.n {
+			t.Errorf("%v samples: kept %v of %v, want %v of %v", tc.n, len(rs.samples), rs.count, tc.kept, tc.n)
+		}
+		if !rs.samples[0].at.Equal(start.Add(time.Duration(tc.first) * time.Second)) {
+			t.Errorf("%v samples: first kept at %v, want sample %v", tc.n, rs.samples[0].at, tc.first)
+		}
+		n := float64(rs.count)
+		if rs.cpuSum/n != tc.cpuMean || rs.cpuMax != tc.cpuMax || rs.memorySum/n != tc.memoryMB {
+			t.Errorf("%v samples: cpu mean %v max %v, memory mean %v, want %v %v %v", tc.n, rs.cpuSum/n, rs.cpuMax, rs.memorySum/n, tc.cpuMean, tc.cpuMax, tc.memoryMB)
+		}
+	}
+}
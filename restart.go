@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ac "github.com/docker/docker/api/types/container"
+)
+
+// parseRestartPolicy parses a restart policy written like for docker run:
+// no, always, unless-stopped, on-failure or on-failure:<max retries>.
+func parseRestartPolicy(s string) (ac.RestartPolicy, error) {
+	name, retries := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		name, retries = s[:i], s[i+1:]
+	}
+	policy := ac.RestartPolicy{Name: name}
+	switch name {
+	case "no", "always", "unless-stopped":
+		if retries != "" {
+			return policy, fmt.Errorf("restart policy %s does not take a maximum retry count", name)
+		}
+	case "on-failure":
+		if retries != "" {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return policy, fmt.Errorf("invalid maximum retry count %q", retries)
+			}
+			policy.MaximumRetryCount = n
+		}
+	default:
+		return policy, fmt.Errorf("unknown restart policy %q, expected no, on-failure[:max-retries], always or unless-stopped", name)
+	}
+	return policy, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
+)
+
+// privilegedPorts are the host ports below which a rootless daemon can not
+// bind.
+const privilegedPorts = 1024
+
+// isRootless tells whether the daemon runs rootless, false when it can not
+// be known.
+func (h *host) isRootless() bool {
+	if err := h.fetchPlatform(); err != nil {
+		logrus.WithError(err).WithField("host", h.name).Debug("could not get daemon security options, rootful assumed")
+		return false
+	}
+	return h.rootless
+}
+
+func rootlessSecurityOptions(options []string) bool {
+	for _, opt := range options {
+		for _, field := range strings.Split(opt, ",") {
+			if field == "name=rootless" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prepareRootless downgrades what a rootless daemon can not honor in a copy
+// created on it, typically from a source on a rootful daemon, with a warning
+// for each instead of failing the copy: privileged host ports are left to
+// the daemon, shared mount propagation is dropped, as is a negative oom
+// score, and so are the cgroup settings without cgroup v2.
+func prepareRootless(log *logrus.Entry, h *host, spec *copySpec) {
+	hc := spec.HostConfig
+	if hc == nil {
+		return
+	}
+	log = log.WithField("host", h.name)
+	for port, bindings := range hc.PortBindings {
+		for i, binding := range bindings {
+			if p, err := nat.ParsePort(binding.HostPort); err == nil && p > 0 && p < privilegedPorts {
+				log.WithField("port", port).WithField("host_port", p).Warn("rootless daemon can not bind privileged port, host port chosen by the daemon")
+				bindings[i].HostPort = ""
+			}
+		}
+	}
+	for i, m := range hc.Mounts {
+		if m.BindOptions != nil && sharedPropagation(string(m.BindOptions.Propagation)) {
+			log.WithField("mount", m.Target).WithField("propagation", m.BindOptions.Propagation).Warn("rootless daemon can not share mount propagation, dropped")
+			hc.Mounts[i].BindOptions.Propagation = ""
+		}
+	}
+	for i, bind := range hc.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		modes := []string{}
+		for _, mode := range strings.Split(parts[len(parts)-1], ",") {
+			if sharedPropagation(mode) {
+				log.WithField("mount", parts[len(parts)-2]).WithField("propagation", mode).Warn("rootless daemon can not share mount propagation, dropped")
+				continue
+			}
+			modes = append(modes, mode)
+		}
+		parts[len(parts)-1] = strings.Join(modes, ",")
+		if len(modes) == 0 {
+			parts = parts[:len(parts)-1]
+		}
+		hc.Binds[i] = strings.Join(parts, ":")
+	}
+	if hc.OomScoreAdj < 0 {
+		log.WithField("oom_score_adj", hc.OomScoreAdj).Warn("rootless daemon can not lower oom score, dropped")
+		hc.OomScoreAdj = 0
+	}
+	if h.cgroupVersion == "2" {
+		return
+	}
+	r := hc.Resources
+	supported := ac.Resources{Devices: r.Devices, DeviceRequests: r.DeviceRequests, Ulimits: r.Ulimits}
+	if !reflect.DeepEqual(r, supported) {
+		log.WithField("cgroup_version", h.cgroupVersion).Warn("rootless daemon can not apply cgroup settings without cgroup v2, resource limits dropped")
+		hc.Resources = supported
+	}
+}
+
+func sharedPropagation(mode string) bool {
+	switch mount.Propagation(mode) {
+	case mount.PropagationShared, mount.PropagationRShared:
+		return true
+	}
+	return false
+}
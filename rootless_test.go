@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRootlessSecurityOptions(t *testing.T) {
+	tests := []struct {
+		options []string
+		want    bool
+	}{
+		{[]string{"name=seccomp,profile=default", "name=rootless"}, true},
+		{[]string{"name=seccomp,profile=default", "name=cgroupns"}, false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := rootlessSecurityOptions(test.options); got != test.want {
+			t.Errorf("rootlessSecurityOptions(%v) = %v, want %v", test.options, got, test.want)
+		}
+	}
+}
+
+func TestPrepareRootless(t *testing.T) {
+	oom := -500
+	tests := []struct {
+		name          string
+		cgroupVersion string
+		hc            ac.HostConfig
+		want          ac.HostConfig
+	}{
+		{
+			name:          "privileged ports",
+			cgroupVersion: "2",
+			hc:            ac.HostConfig{PortBindings: nat.PortMap{"80/tcp": {{HostPort: "80"}}, "8080/tcp": {{HostPort: "8080"}, {HostPort: ""}}}},
+			want:          ac.HostConfig{PortBindings: nat.PortMap{"80/tcp": {{HostPort: ""}}, "8080/tcp": {{HostPort: "8080"}, {HostPort: ""}}}},
+		},
+		{
+			name:          "shared propagation",
+			cgroupVersion: "2",
+			hc: ac.HostConfig{
+				Binds:  []string{"/data:/data:ro,rshared", "/cache:/cache:shared", "/logs:/logs"},
+				Mounts: []mount.Mount{{Type: mount.TypeBind, Source: "/a", Target: "/a", BindOptions: &mount.BindOptions{Propagation: mount.PropagationShared}}},
+			},
+			want: ac.HostConfig{
+				Binds:  []string{"/data:/data:ro", "/cache:/cache", "/logs:/logs"},
+				Mounts: []mount.Mount{{Type: mount.TypeBind, Source: "/a", Target: "/a", BindOptions: &mount.BindOptions{}}},
+			},
+		},
+		{
+			name:          "negative oom score",
+			cgroupVersion: "2",
+			hc:            ac.HostConfig{OomScoreAdj: oom},
+			want:          ac.HostConfig{},
+		},
+		{
+			name:          "cgroup v2 limits kept",
+			cgroupVersion: "2",
+			hc:            ac.HostConfig{Resources: ac.Resources{Memory: 1 << 30, NanoCPUs: 1e9}},
+			want:          ac.HostConfig{Resources: ac.Resources{Memory: 1 << 30, NanoCPUs: 1e9}},
+		},
+		{
+			name:          "cgroup v1 limits dropped",
+			cgroupVersion: "1",
+			hc:            ac.HostConfig{Resources: ac.Resources{Memory: 1 << 30, CgroupParent: "bubble", Ulimits: []*units.Ulimit{{Name: "nofile", Soft: 1024, Hard: 1024}}}},
+			want:          ac.HostConfig{Resources: ac.Resources{Ulimits: []*units.Ulimit{{Name: "nofile", Soft: 1024, Hard: 1024}}}},
+		},
+	}
+	for _, test := range tests {
+		h := &host{name: "rootless", ostype: "linux", rootless: true, cgroupVersion: test.cgroupVersion}
+		hc := test.hc
+		spec := copySpec{Config: &ac.Config{}, HostConfig: &hc}
+		prepareRootless(logrus.NewEntry(logrus.StandardLogger()), h, &spec)
+		if !reflect.DeepEqual(*spec.HostConfig, test.want) {
+			t.Errorf("%s: prepareRootless = %+v, want %+v", test.name, *spec.HostConfig, test.want)
+		}
+	}
+}
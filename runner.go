@@ -0,0 +1,322 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runner runs a cycle at every tick of the frequency until it is stopped.
+type runner struct {
+	hosts   []*host
+	opts    *options
+	started time.Time
+	stats   stats
+	budget  budget
+	targets *targetsWatcher
+	statsd  *statsd
+	// orchestrator is churned instead of the containers of the hosts when
+	// the backend is not docker.
+	orchestrator orchestrator
+	// elector is nil without leader election.
+	elector *elector
+	// coordinator is nil without coordination.
+	coordinator *coordinator
+	// txlog is nil without transaction log.
+	txlog *txLog
+	// leases are nil when disabled.
+	leases *leases
+	// logger is the logger the cycles log with.
+	logger *logrus.Logger
+	// log is the logger of the running cycle, tagging its lines with the
+	// cycle id.
+	log *logrus.Entry
+	// snapshots are the images committed from sources, by host and target.
+	snapshots map[string]string
+	// externals are nil with another backend than docker.
+	externals *externals
+	// candidates are nil without candidate cache.
+	candidates *candidateCache
+	// drift is nil without drift alerting.
+	drift *drift
+	// notifications are nil without notifiers.
+	notifications *notifications
+	// pool is nil without standby pool.
+	pool *standbyPool
+	// frozen is nil without --freeze-source.
+	frozen *frozenSources
+	// resources is the time series of --stats-interval.
+	resources resourceSeries
+	// interrupted tells the run was stopped by a signal.
+	interrupted bool
+}
+
+func newRunner(hosts []*host, opts *options) (*runner, error) {
+	r := &runner{hosts: hosts, opts: opts, log: logrus.NewEntry(logrus.StandardLogger()), budget: budget{limit: opts.errorBudget, min: opts.errorBudgetMinOps}, orchestrator: newOrchestrator(opts)}
+	if r.orchestrator == nil {
+		for _, h := range hosts {
+			if _, err := checkAPIVersion(h); err != nil {
+				return nil, err
+			}
+			logrus.WithField("host", h.name).WithField("version", h.client.ClientVersion()).Info("docker api version")
+		}
+		if err := checkComposeCandidates(hosts, opts.targets, opts.states); err != nil {
+			return nil, err
+		}
+	}
+	if opts.redisAddr != "" && opts.leaderKey != "" {
+		r.elector = newElector(opts)
+	}
+	if opts.coordinateKey != "" {
+		r.coordinator = newCoordinator(opts)
+	}
+	if opts.lease && r.orchestrator == nil {
+		r.leases = newLeases()
+	}
+	if len(opts.notifiers) > 0 {
+		r.notifications = newNotifications(opts.notifiers)
+		bus.subscribe(r.notifications)
+	}
+	if opts.reconcileInterval > 0 && r.orchestrator == nil {
+		r.candidates = newCandidateCache(opts.reconcileInterval)
+	}
+	if opts.standbyPool > 0 && r.orchestrator == nil {
+		r.pool = newStandbyPool(opts.standbyPool)
+	}
+	if opts.freezeSource {
+		r.frozen = newFrozenSources()
+		if err := r.frozen.freeze(hosts, opts.targets, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.driftThreshold > 0 && r.orchestrator == nil {
+		r.drift = newDrift(opts.driftThreshold)
+	}
+	r.logger = logrus.StandardLogger()
+	if opts.logMode == logModeSummary {
+		r.logger = summaryLogger()
+		bus.subscribe(&cycleSummary{})
+	}
+	if opts.targetsFile != "" {
+		r.targets = &targetsWatcher{path: opts.targetsFile}
+	}
+	if opts.stateFile != "" {
+		if err := r.loadState(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.statsdAddr != "" {
+		s, err := newStatsd(opts.statsdAddr)
+		if err != nil {
+			return nil, err
+		}
+		r.statsd = s
+	}
+	return r, nil
+}
+
+// run returns false when the run failed the soak test thresholds.
+func (r *runner) run() bool {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	var deadline <-chan time.Time
+	if r.opts.duration > 0 {
+		deadline = time.After(r.opts.duration)
+	}
+
+	var sampling <-chan time.Time
+	if r.opts.statsInterval > 0 {
+		ticker := time.NewTicker(r.opts.statsInterval)
+		defer ticker.Stop()
+		sampling = ticker.C
+	}
+
+	var watchdog <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	// the cycles tick on their own ticker so that the other events do not
+	// postpone them. Independent creations and deletions replace them.
+	var cycleTicks, addTicks, removeTicks <-chan time.Time
+	if r.opts.addEvery > 0 || r.opts.removeEvery > 0 {
+		if r.opts.addEvery > 0 {
+			ticker := time.NewTicker(r.opts.addEvery)
+			defer ticker.Stop()
+			addTicks = ticker.C
+		}
+		if r.opts.removeEvery > 0 {
+			ticker := time.NewTicker(r.opts.removeEvery)
+			defer ticker.Stop()
+			removeTicks = ticker.C
+		}
+	} else {
+		ticker := time.NewTicker(r.opts.freq)
+		defer ticker.Stop()
+		cycleTicks = ticker.C
+	}
+
+	var targetsPoll <-chan time.Time
+	if r.targets != nil {
+		ticker := time.NewTicker(r.opts.targetsPoll)
+		defer ticker.Stop()
+		targetsPoll = ticker.C
+	}
+
+	var campaign <-chan time.Time
+	if r.elector != nil {
+		r.elector.campaign()
+		ticker := time.NewTicker(r.opts.leaderTTL / 3)
+		defer ticker.Stop()
+		campaign = ticker.C
+	}
+
+	// container events tell which containers die, and turn unhealthy.
+	var containerEvents <-chan containerEvent
+	if r.orchestrator == nil {
+		names := append(append([]string{"oom"}, inspectEvents...), candidateEvents...)
+		if r.opts.replaceUnhealthy {
+			names = append(names, "health_status")
+		}
+		containerEvents = watchEvents(r.hosts, names...)
+		inspections.enable(r.opts.inspectCacheTTL)
+	}
+
+	r.started = time.Now()
+	if r.opts.transactionLog != "" {
+		r.txlog = newTxLog(r.opts.transactionLog, r.started)
+	}
+	if r.orchestrator == nil {
+		r.externals = newExternals(r.opts.externalPolicy, r.started)
+	}
+	r.reloadTargets()
+	sdNotify("READY=1")
+	for {
+		select {
+		case <-cycleTicks:
+			if r.cycle(nil) {
+				return r.finish()
+			}
+		case <-addTicks:
+			if r.cycle(&r.opts.addRatio) {
+				return r.finish()
+			}
+		case <-removeTicks:
+			if r.cycle(&r.opts.removeRatio) {
+				return r.finish()
+			}
+		case e := <-containerEvents:
+			inspections.invalidate(e.host, e.message.Actor.ID)
+			if r.candidates != nil {
+				r.candidates.update(e, r.opts.states)
+			}
+			r.recordDeath(e)
+			if e.message.Action != unhealthyAction || isPaused() || r.elector != nil && !r.elector.leader {
+				continue
+			}
+			r.log = cycleLogger(r.logger)
+			if err := r.replaceUnhealthy(e); err != nil {
+				r.log.WithError(err).WithField("container", e.message.Actor.ID).Error("could not replace unhealthy container")
+			}
+		case <-targetsPoll:
+			r.reloadTargets()
+		case <-sampling:
+			r.sampleResources()
+		case <-campaign:
+			r.elector.campaign()
+		case <-watchdog:
+			sdNotify("WATCHDOG=1")
+		case <-deadline:
+			logrus.Info("run duration elapsed")
+			return r.finish()
+		case <-sig:
+			logrus.Info("received stop signal")
+			r.interrupted = true
+			return r.finish()
+		}
+	}
+}
+
+// cycle runs a cycle with the ratio, or with the ratio of the options when
+// nil, unless paused or standing by, and tells whether the run is over.
+func (r *runner) cycle(ratio *RatioValue) bool {
+	if isPaused() {
+		logrus.Info("paused, cycle skipped")
+		return false
+	}
+	if r.elector != nil && !r.elector.leader {
+		logrus.Debug("standing by, cycle skipped")
+		return false
+	}
+	r.reloadTargets()
+	r.stats.cycles++
+	began := time.Now()
+	timings.reset()
+	r.log = cycleLogger(r.logger)
+	cycle := busEvent{Kind: eventCycleFinished, Cycle: r.stats.cycles, CycleID: cycleIDOf(r.log)}
+	if err := r.job(ratio); err != nil {
+		r.stats.failedCycles++
+		r.log.WithError(err).Error("job failed")
+		cycle.Err = err
+	}
+	cycle.Duration = time.Since(began)
+	logTimings(r.log, timings.reset())
+	bus.publish(cycle)
+	r.runProbe()
+	r.exportMetrics()
+	if r.opts.stateFile != "" {
+		if err := r.saveState(); err != nil {
+			r.log.WithError(err).Error("could not save state")
+		}
+	}
+	if r.budget.exceeded() {
+		logrus.WithField("failures", r.budget.failures).WithField("operations", r.budget.ops).Error("error budget exceeded, aborting")
+		return true
+	}
+	if r.opts.maxCycles > 0 && r.stats.cycles >= r.opts.maxCycles {
+		logrus.WithField("cycles", r.stats.cycles).Info("maximum number of cycles reached")
+		return true
+	}
+	return false
+}
+
+func (r *runner) finish() bool {
+	sdNotify("STOPPING=1")
+	if r.leases != nil {
+		r.leases.release()
+	}
+	if r.pool != nil {
+		r.pool.release(r.log)
+	}
+	if r.elector != nil {
+		r.elector.resign()
+	}
+	if r.coordinator != nil {
+		r.coordinator.redis.Close()
+	}
+	if r.opts.pruneImages {
+		pruneImages(r.hosts)
+	}
+	if r.opts.stateFile != "" {
+		if err := r.saveState(); err != nil {
+			logrus.WithError(err).Error("could not save state")
+		}
+	}
+	r.printResources()
+	r.printExits()
+	if r.notifications != nil {
+		r.notifications.shutdown(Shutdown{Cycles: r.stats.cycles, FailedCycles: r.stats.failedCycles, Uptime: time.Since(r.started)})
+	}
+	if r.opts.duration == 0 {
+		return !r.budget.exceeded()
+	}
+	pass := r.verdict()
+	r.printSummary(pass)
+	return pass
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scaleUp creates count copies of a random container of the first target as
+// fast as possible, parallel ones at a time, spread on the eligible hosts,
+// to quickly stand up a large fleet.
+func scaleUp(hosts []*host, opts *options) error {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	t := opts.targets[0]
+	candidates, err := listCandidates(hosts, t, opts.states)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no container matches %s", t)
+	}
+	eligible, err := eligibleHosts(hosts, opts.constraints)
+	if err != nil {
+		return err
+	}
+	if len(eligible) == 0 {
+		return fmt.Errorf("no host satisfies placement constraints")
+	}
+	source := pickSource(candidates, opts.copySource)
+	infos, err := source.host.client.ContainerInspect(context.Background(), source.ID)
+	if err != nil {
+		return fmt.Errorf("could not inspect container id %s: %w", source.ID, err)
+	}
+	sourceConfig := sourceSpec(infos)
+	targets := pickHosts(eligible, uint64(opts.count), opts.spread)
+	if err := prepareTargets(source.host, infos, &sourceConfig, targets, opts); err != nil {
+		return err
+	}
+
+	// specs are prepared sequentially, their preparation sharing state
+	// across copies, only their creation is parallel.
+	specs := make([]copySpec, len(targets))
+	number := nextComposeNumber(candidates)
+	for i, target := range targets {
+		spec, err := newCopySpec(sourceConfig, source.ID, t, number+i, opts)
+		if err != nil {
+			return fmt.Errorf("could not prepare copy of container id %s: %w", source.ID, err)
+		}
+		if target.osType() == osWindows {
+			prepareWindows(&spec)
+		}
+		specs[i] = spec
+	}
+
+	began := time.Now()
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for w := 0; w < opts.parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if _, err := createContainer(log, targets[i], specs[i], opts); err != nil {
+					logrus.WithError(err).WithField("host", targets[i].name).Error("could not create copy")
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	elapsed := time.Since(began)
+	created := len(targets) - failed
+	fmt.Printf("created %d copies of %s in %v, %.2f copies/s\n", created, shortID(source.ID), elapsed.Round(time.Millisecond), float64(created)/elapsed.Seconds())
+	if failed > 0 {
+		return fmt.Errorf("%d copies could not be created", failed)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// window is a daily time range during which a specific ratio applies.
+type window struct {
+	from  time.Duration
+	to    time.Duration
+	ratio RatioValue
+}
+
+// parseWindow parses a window written as 09:00-12:00=3:1. A window whose end
+// is before its start spans midnight.
+func parseWindow(s string) (window, error) {
+	var w window
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return w, fmt.Errorf("invalid schedule %q, expected HH:MM-HH:MM=up:down", s)
+	}
+	bounds := strings.Split(parts[0], "-")
+	if len(bounds) != 2 {
+		return w, fmt.Errorf("invalid schedule %q, expected HH:MM-HH:MM=up:down", s)
+	}
+	for i, out := range []*time.Duration{&w.from, &w.to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(bounds[i]))
+		if err != nil {
+			return w, fmt.Errorf("invalid schedule %q: %w", s, err)
+		}
+		*out = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if err := w.ratio.Set(strings.TrimSpace(parts[1])); err != nil {
+		return w, fmt.Errorf("invalid schedule %q: %w", s, err)
+	}
+	return w, nil
+}
+
+func (w window) contains(t time.Time) bool {
+	at := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.from <= w.to {
+		return w.from <= at && at < w.to
+	}
+	return at >= w.from || at < w.to
+}
+
+// ratioAt returns the ratio of the first window containing t, or the base
+// ratio outside of every window.
+func ratioAt(opts *options, t time.Time) RatioValue {
+	for _, w := range opts.windows {
+		if w.contains(t) {
+			return w.ratio
+		}
+	}
+	return opts.ratio
+}
+
+// ratioAt returns the ratio of the cycle: the given one, of independent
+// creations or deletions, or the scheduled one when nil.
+func (r *runner) ratioAt(ratio *RatioValue, t time.Time) RatioValue {
+	if ratio != nil {
+		return *ratio
+	}
+	return ratioAt(r.opts, t)
+}
+
+// rampRatio scales the ratio linearly from zero when the run starts to its
+// full value once the ramp duration has elapsed.
+func rampRatio(ratio RatioValue, elapsed, ramp time.Duration) RatioValue {
+	if ramp <= 0 || elapsed >= ramp {
+		return ratio
+	}
+	factor := float64(elapsed) / float64(ramp)
+	return RatioValue{
+		Up:          uint64(math.Round(float64(ratio.Up) * factor)),
+		Down:        uint64(math.Round(float64(ratio.Down) * factor)),
+		UpPercent:   ratio.UpPercent,
+		DownPercent: ratio.DownPercent,
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in   string
+		want window
+		err  bool
+	}{
+		{"09:00-12:30=3:1", window{from: 9 * time.Hour, to: 12*time.Hour + 30*time.Minute, ratio: RatioValue{Up: 3, Down: 1}}, false},
+		{"22:00-06:00=1:2", window{from: 22 * time.Hour, to: 6 * time.Hour, ratio: RatioValue{Up: 1, Down: 2}}, false},
+		{" 09:00 - 10:00 = 1:1", window{from: 9 * time.Hour, to: 10 * time.Hour, ratio: RatioValue{Up: 1, Down: 1}}, false},
+		{"09:00-12:00", window{}, true},
+		{"09:00=1:1", window{}, true},
+		{"25:00-26:00=1:1", window{}, true},
+		{"09:00-10:00=x", window{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseWindow(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	day := window{from: 9 * time.Hour, to: 17 * time.Hour}
+	night := window{from: 22 * time.Hour, to: 6 * time.Hour}
+	tests := []struct {
+		w    window
+		at   string
+		want bool
+	}{
+		{day, "09:00", true},
+		{day, "12:00", true},
+		{day, "16:59", true},
+		{day, "17:00", false},
+		{day, "08:59", false},
+		{night, "22:00", true},
+		{night, "23:59", true},
+		{night, "00:00", true},
+		{night, "05:59", true},
+		{night, "06:00", false},
+		{night, "12:00", false},
+		{night, "21:59", false},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse("15:04", tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tt.w.contains(at); got != tt.want {
+			t.Errorf("window %v-%v contains %s: got %v, want %v", tt.w.from, tt.w.to, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestRatioPercent(t *testing.T) {
+	var r RatioValue
+	if err := r.Set("10%:3"); err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "10%:3" {
+		t.Errorf("got ratio %s, want 10%%:3", r.String())
+	}
+	for count, want := range map[int]RatioValue{0: {Up: 0, Down: 3}, 4: {Up: 0, Down: 3}, 15: {Up: 2, Down: 3}, 200: {Up: 20, Down: 3}} {
+		if got := r.resolve(count); got != want {
+			t.Errorf("%v containers: got %+v, want %+v", count, got, want)
+		}
+	}
+	if got := rampRatio(r, time.Minute, 2*time.Minute).resolve(100); got != (RatioValue{Up: 5, Down: 2}) {
+		t.Errorf("got ramped ratio %+v, want 5:2", got)
+	}
+	if err := r.Set("1:150%"); err == nil {
+		t.Error("deleting more than the fleet accepted")
+	}
+}
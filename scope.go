@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parseScope parses the --scope flags, key=value labels.
+func parseScope(flags []string) (map[string]string, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	scope := map[string]string{}
+	for _, s := range flags {
+		i := strings.Index(s, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid scope %q, expected key=value", s)
+		}
+		scope[s[:i]] = s[i+1:]
+	}
+	return scope, nil
+}
+
+func formatScope(scope map[string]string) string {
+	labels := make([]string, 0, len(scope))
+	for key, value := range scope {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+// scoped restricts the targets to the scope.
+func scoped(targets []target, scope map[string]string) []target {
+	for i := range targets {
+		targets[i].scope = scope
+	}
+	return targets
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestScope(t *testing.T) {
+	if _, err := parseScope([]string{"team"}); err == nil {
+		t.Error("scope without value accepted")
+	}
+	scope, err := parseScope([]string{"team=payments", "env=prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := scoped([]target{{image: "redis"}}, scope)[0]
+	if got, want := target.String(), "redis[env=prod,team=payments]"; got != want {
+		t.Errorf("got target %s, want %s", got, want)
+	}
+	for _, tc := range []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"team": "payments", "env": "prod"}, true},
+		{map[string]string{"team": "search", "env": "prod"}, false},
+		{map[string]string{"env": "prod"}, false},
+		{nil, false},
+	} {
+		if got := target.matches(types.Container{Image: "redis", Labels: tc.labels}); got != tc.want {
+			t.Errorf("%v: got match %v, want %v", tc.labels, got, tc.want)
+		}
+	}
+}
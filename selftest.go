@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+// selftestLabel marks the containers of a selftest, with the id of the
+// selftest, so that it only churns and cleans up its own containers.
+const selftestLabel = "bubble.selftest"
+
+// selftestReport prints the checks of a selftest and remembers whether one
+// of them failed.
+type selftestReport struct {
+	w      io.Writer
+	failed bool
+}
+
+func (r *selftestReport) check(ok bool, format string, args ...interface{}) bool {
+	status := "ok  "
+	if !ok {
+		status = "FAIL"
+		r.failed = true
+	}
+	fmt.Fprintf(r.w, "%s %s\n", status, fmt.Sprintf(format, args...))
+	return ok
+}
+
+// selftest starts a disposable container of the selftest image on the first
+// host, churns it for a few cycles with a 1:1 ratio, checking after each one
+// that the fleet kept its size and was churned, then removes every container
+// it created and checks none is left.
+func selftest(hosts []*host, opts *options) error {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	h := hosts[0]
+	id := newID()
+	t := target{image: opts.selftestImage, weight: 1, scope: map[string]string{selftestLabel: id}}
+	report := &selftestReport{w: os.Stdout}
+	fmt.Fprintf(report.w, "selftest %s on host %s with %s\n", id, h.name, opts.selftestImage)
+	defer func() {
+		report.check(cleanSelftest(log, h, id) == nil, "cleanup: no container left")
+		if report.failed {
+			fmt.Fprintln(report.w, "FAIL")
+		} else {
+			fmt.Fprintln(report.w, "PASS")
+		}
+	}()
+
+	if err := pullImage(h, opts.selftestImage, "", opts); err != nil {
+		report.check(false, "pull %s: %v", opts.selftestImage, err)
+		return err
+	}
+	seed, err := createContainer(log, h, copySpec{
+		Name:       "bubble-selftest-" + randomSuffix(),
+		Config:     &ac.Config{Image: opts.selftestImage, Labels: map[string]string{selftestLabel: id}},
+		HostConfig: &ac.HostConfig{},
+	}, opts)
+	if !report.check(err == nil, "start target container %s", shortID(seed)) {
+		return err
+	}
+
+	opts.targets = []target{t}
+	opts.ratio = RatioValue{Up: 1, Down: 1}
+	opts.mode, opts.minAge = modeChurn, 0
+	r, err := newRunner([]*host{h}, opts)
+	if err != nil {
+		report.check(false, "start runner: %v", err)
+		return err
+	}
+	previous := seed
+	for cycle := 1; cycle <= opts.selftestCycles; cycle++ {
+		r.stats.cycles = cycle
+		r.log = cycleLogger(r.logger)
+		if err := r.job(nil); !report.check(err == nil, "cycle %v", cycle) {
+			return err
+		}
+		candidates, err := listCandidates([]*host{h}, t, []string{stateRunning})
+		if err != nil {
+			report.check(false, "list containers after cycle %v: %v", cycle, err)
+			return err
+		}
+		report.check(len(candidates) == 1, "cycle %v: %v running container, want 1", cycle, len(candidates))
+		if len(candidates) == 1 {
+			report.check(candidates[0].ID != previous, "cycle %v: container %s replaced by %s", cycle, shortID(previous), shortID(candidates[0].ID))
+			previous = candidates[0].ID
+		}
+	}
+	if report.failed {
+		return errors.New("selftest failed")
+	}
+	return nil
+}
+
+// cleanSelftest removes every container of the selftest, then checks none
+// is left.
+func cleanSelftest(log *logrus.Entry, h *host, id string) error {
+	args := filters.NewArgs(filters.Arg("label", selftestLabel+"="+id))
+	containers, err := h.client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return fmt.Errorf("could not list selftest containers: %w", err)
+	}
+	for _, c := range containers {
+		discardContainer(log, h, c.ID, c.Labels[isolatedNetworkLabel])
+	}
+	if containers, err = h.client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: args}); err != nil {
+		return fmt.Errorf("could not list selftest containers: %w", err)
+	}
+	if len(containers) > 0 {
+		return fmt.Errorf("%v selftest containers left", len(containers))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSelftestReport(t *testing.T) {
+	var out bytes.Buffer
+	r := &selftestReport{w: &out}
+	if !r.check(true, "cycle %v", 1) || r.failed {
+		t.Error("passed check failed the selftest")
+	}
+	if r.check(false, "cycle %v", 2) || !r.failed {
+		t.Error("failed check passed the selftest")
+	}
+	r.check(true, "cycle %v", 3)
+	if !r.failed {
+		t.Error("passed check made the selftest pass again")
+	}
+	if got, want := out.String(), "ok   cycle 1\nFAIL cycle 2\nok   cycle 3\n"; got != want {
+		t.Errorf("got report %q, want %q", got, want)
+	}
+}
+
+func TestCleanSelftest(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	for _, tc := range []struct {
+		name   string
+		remove func(string, types.ContainerRemoveOptions) error
+		ok     bool
+	}{
+		{"removed", nil, true},
+		{"left", func(string, types.ContainerRemoveOptions) error { return errors.New("removal in progress") }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			left := []types.Container{{ID: "seed"}, {ID: "copy"}}
+			m := &mockClient{RemoveFunc: tc.remove}
+			m.ListFunc = func(options types.ContainerListOptions) ([]types.Container, error) {
+				if !options.Filters.ExactMatch("label", selftestLabel+"=abc") {
+					t.Errorf("listed containers of other selftests")
+				}
+				return left, nil
+			}
+			if tc.remove == nil {
+				m.RemoveFunc = func(id string, _ types.ContainerRemoveOptions) error {
+					left = left[1:]
+					return nil
+				}
+			}
+			if err := cleanSelftest(log, mockHost(m), "abc"); (err == nil) != tc.ok {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+}
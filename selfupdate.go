@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// releasesURL is the github api of the releases of bubble.
+var releasesURL = "https://api.github.com/repos/fmarmol/bubble/releases"
+
+// checksumsAsset is the asset of a release listing the sha256 of the other
+// assets, one "<sha256>  <asset>" per line.
+const checksumsAsset = "checksums.txt"
+
+// release is the part of a github release self-update reads.
+type release struct {
+	Tag    string         `json:"tag_name"`
+	Assets []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r release) asset(name string) (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// binaryAsset is the name of the release asset of the binary of the platform
+// bubble runs on, e.g. bubble_linux_amd64.
+func binaryAsset() string {
+	name := "bubble_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == osWindows {
+		name += ".exe"
+	}
+	return name
+}
+
+var releaseClient = &http.Client{Timeout: 5 * time.Minute}
+
+// selfUpdate replaces the running binary with the one of the latest release,
+// or of the release of --to, after verifying its checksum, and its cosign
+// signature with --verify-key. The binary is replaced atomically, through a
+// temporary file renamed over it.
+func selfUpdate(w io.Writer, opts *options) error {
+	rel, err := fetchRelease(opts.selfUpdateTo)
+	if err != nil {
+		return err
+	}
+	current := buildVersion()
+	if rel.Tag == current {
+		fmt.Fprintf(w, "bubble %s is up to date\n", current)
+		return nil
+	}
+	if opts.selfUpdateCheck {
+		fmt.Fprintf(w, "bubble %s is available, running %s\n", rel.Tag, current)
+		return nil
+	}
+	binary, ok := rel.asset(binaryAsset())
+	if !ok {
+		return fmt.Errorf("release %s has no binary %s", rel.Tag, binaryAsset())
+	}
+	checksums, ok := rel.asset(checksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s", rel.Tag, checksumsAsset)
+	}
+	data, err := download(checksums.URL)
+	if err != nil {
+		return err
+	}
+	want, err := assetChecksum(data, binary.Name)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find bubble binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("could not find bubble binary: %w", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(exe), filepath.Base(exe)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write new binary: %w", err)
+	}
+	defer os.Remove(f.Name())
+	got, err := downloadTo(f, binary.URL)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write new binary: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("checksum of %s is %s, expected %s", binary.Name, got, want)
+	}
+	if opts.verifyKey != "" {
+		if err := verifyBlob(f.Name(), rel, binary.Name, opts.verifyKey); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(f.Name(), 0o755); err != nil {
+		return fmt.Errorf("could not write new binary: %w", err)
+	}
+	if err := replaceBinary(f.Name(), exe); err != nil {
+		return err
+	}
+	logrus.WithField("from", current).WithField("to", rel.Tag).WithField("path", exe).Info("bubble updated")
+	fmt.Fprintf(w, "bubble updated from %s to %s\n", current, rel.Tag)
+	return nil
+}
+
+// fetchRelease returns the release of the tag, the latest one when empty.
+func fetchRelease(tag string) (release, error) {
+	url := releasesURL + "/latest"
+	if tag != "" {
+		url = releasesURL + "/tags/" + tag
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := releaseClient.Do(req)
+	if err != nil {
+		return release{}, fmt.Errorf("could not get release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("could not get release: %s", resp.Status)
+	}
+	var rel release
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return release{}, fmt.Errorf("could not decode release: %w", err)
+	}
+	return rel, nil
+}
+
+func download(url string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := downloadTo(&buf, url); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadTo writes the content at the url to w and returns its sha256.
+func downloadTo(w io.Writer, url string) (string, error) {
+	resp, err := releaseClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("could not download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not download %s: %s", url, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return "", fmt.Errorf("could not download %s: %w", url, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// assetChecksum returns the sha256 of the asset from the checksums.
+func assetChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum of %s in %s", name, checksumsAsset)
+}
+
+// verifyBlob verifies the binary against its signature, the .sig asset of
+// the release, with the cosign key.
+func verifyBlob(path string, rel release, name, key string) error {
+	signature, ok := rel.asset(name + ".sig")
+	if !ok {
+		return fmt.Errorf("release %s has no signature of %s", rel.Tag, name)
+	}
+	data, err := download(signature.URL)
+	if err != nil {
+		return err
+	}
+	sig, err := ioutil.TempFile("", "bubble-*.sig")
+	if err != nil {
+		return fmt.Errorf("could not write signature: %w", err)
+	}
+	defer os.Remove(sig.Name())
+	_, err = sig.Write(data)
+	if cerr := sig.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write signature: %w", err)
+	}
+	cmd := exec.Command("cosign", "verify-blob", "--key", key, "--signature", sig.Name(), path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not verify signature of %s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// replaceBinary renames the new binary over the running one. Windows does
+// not allow it while it runs, the running one is moved aside first.
+func replaceBinary(path, exe string) error {
+	if runtime.GOOS == osWindows {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("could not replace bubble binary: %w", err)
+		}
+	}
+	if err := os.Rename(path, exe); err != nil {
+		return fmt.Errorf("could not replace bubble binary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAssetChecksum(t *testing.T) {
+	checksums := []byte("ABC123  bubble_linux_amd64\ndef456 *bubble_darwin_arm64\n\n")
+	tests := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"bubble_linux_amd64", "abc123", false},
+		{"bubble_darwin_arm64", "def456", false},
+		{"bubble_windows_amd64.exe", "", true},
+	}
+	for _, test := range tests {
+		got, err := assetChecksum(checksums, test.name)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("assetChecksum(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest", "/releases/tags/v9.9.9":
+			json.NewEncoder(w).Encode(release{Tag: "v9.9.9", Assets: []releaseAsset{
+				{Name: binaryAsset(), URL: srv.URL + "/binary"},
+				{Name: checksumsAsset, URL: srv.URL + "/checksums"},
+			}})
+		case "/releases/tags/v0.0.1":
+			json.NewEncoder(w).Encode(release{Tag: "v0.0.1"})
+		case "/binary":
+			fmt.Fprint(w, "not the binary of the checksums")
+		case "/checksums":
+			fmt.Fprintf(w, "%s  %s\n", strings.Repeat("0", 64), binaryAsset())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer func(url string) { releasesURL = url }(releasesURL)
+	releasesURL = srv.URL + "/releases"
+	defer func(v string) { version = v }(version)
+	version = "v1.0.0"
+
+	tests := []struct {
+		to    string
+		check bool
+		out   string
+		err   string
+	}{
+		{to: "", check: true, out: "bubble v9.9.9 is available, running v1.0.0\n"},
+		{to: "v9.9.9", check: true, out: "bubble v9.9.9 is available, running v1.0.0\n"},
+		{to: "v1.0.0", err: "could not get release: 404 Not Found"},
+		{to: "v0.0.1", err: "release v0.0.1 has no binary " + binaryAsset()},
+		{to: "", err: "checksum of " + binaryAsset() + " is"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := selfUpdate(&out, &options{selfUpdateTo: test.to, selfUpdateCheck: test.check})
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("selfUpdate(%q) = %v, want %q", test.to, err, test.err)
+		}
+		if out.String() != test.out {
+			t.Errorf("selfUpdate(%q) printed %q, want %q", test.to, out.String(), test.out)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// simulate projects the size of the fleet of a target over the cycles from
+// the ratio, schedule, ramp, mode and minimum age, without contacting
+// docker: every copy is assumed to be created and every deletion to
+// succeed, a cycle deleting more containers than it can failing like a real
+// one.
+func simulate(opts *options) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CYCLE\tTIME\tRATIO\tCREATED\tDELETED\tFLEET")
+	start := time.Now()
+	// created are the creation times of the containers of the fleet, the
+	// initial ones being old enough to be deleted.
+	created := make([]time.Time, opts.initial)
+	for i := range created {
+		created[i] = start.Add(-opts.minAge)
+	}
+	fmt.Fprintf(w, "0\t%s\t\t\t\t%d\n", start.Format("15:04:05"), len(created))
+	failed := 0
+	for cycle := 1; cycle <= opts.simulateCycles; cycle++ {
+		now := start.Add(time.Duration(cycle) * opts.freq)
+		if opts.duration > 0 && now.Sub(start) > opts.duration {
+			break
+		}
+		ratio := rampRatio(ratioAt(opts, now), now.Sub(start), opts.ramp).resolve(len(created))
+		up, down := int(ratio.Up), int(ratio.Down)
+		switch opts.mode {
+		case modeUp:
+			down = 0
+		case modeDown:
+			up = 0
+		}
+		deletable := []int{}
+		for i, t := range created {
+			if now.Sub(t) >= opts.minAge {
+				deletable = append(deletable, i)
+			}
+		}
+		status := ""
+		switch {
+		case len(created) == 0:
+			// a cycle without candidates does nothing.
+			up, down = 0, 0
+		case down > len(deletable):
+			up, down = 0, 0
+			status = " (failed)"
+			failed++
+		}
+		removed := map[int]bool{}
+		for _, i := range rand.Perm(len(deletable))[:down] {
+			removed[deletable[i]] = true
+		}
+		next := []time.Time{}
+		for i, t := range created {
+			if !removed[i] {
+				next = append(next, t)
+			}
+		}
+		for i := 0; i < up; i++ {
+			next = append(next, now)
+		}
+		created = next
+		fmt.Fprintf(w, "%d\t%s\t%d:%d\t%d\t%d\t%d%s\n", cycle, now.Format("15:04:05"), ratio.Up, ratio.Down, up, down, len(created), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		fmt.Printf("%d cycles would fail deleting more containers than the fleet has old enough\n", failed)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/sirupsen/logrus"
+)
+
+// clockSkewLabel holds the clock offset of skewed copies.
+const clockSkewLabel = "bubble.clock-skew"
+
+// faketimeOffset is a relative libfaketime offset, e.g. +5m or -30s.
+var faketimeOffset = regexp.MustCompile(`^[+-][0-9]+(\.[0-9]+)?[smhdy]?$`)
+
+func checkClockOffsets(offsets []string) error {
+	for _, offset := range offsets {
+		if !faketimeOffset.MatchString(offset) {
+			return fmt.Errorf("invalid clock offset %q, expected e.g. +5m or -30s", offset)
+		}
+	}
+	return nil
+}
+
+// skewClock makes a share of the copies see a skewed clock through
+// libfaketime: FAKETIME is set to a random offset among the configured
+// ones and, when a library is given, it is mounted from the host and
+// preloaded.
+func skewClock(spec *copySpec, opts *options) {
+	if !opts.clockSkew.set || len(opts.clockOffsets) == 0 || rand.Float64() >= opts.clockSkew.value {
+		return
+	}
+	offset := opts.clockOffsets[rand.Intn(len(opts.clockOffsets))]
+	spec.Config.Env = setEnv(spec.Config.Env, "FAKETIME", offset)
+	spec.Config.Labels[clockSkewLabel] = offset
+	if opts.faketimeLib != "" {
+		preload := opts.faketimeLib
+		if current, ok := getEnv(spec.Config.Env, "LD_PRELOAD"); ok && current != "" {
+			preload = current + ":" + preload
+		}
+		spec.Config.Env = setEnv(spec.Config.Env, "LD_PRELOAD", preload)
+		if spec.HostConfig != nil {
+			spec.HostConfig.Mounts = append(spec.HostConfig.Mounts, mount.Mount{
+				Type:     mount.TypeBind,
+				Source:   opts.faketimeLib,
+				Target:   opts.faketimeLib,
+				ReadOnly: true,
+			})
+		}
+	}
+	logrus.WithField("name", spec.Name).WithField("offset", offset).Info("skew copy clock")
+}
+
+func getEnv(env []string, name string) (string, bool) {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, name+"=") {
+			return kv[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// setEnv sets the variable in env, replacing its current value if any.
+func setEnv(env []string, name, value string) []string {
+	for i, kv := range env {
+		if strings.HasPrefix(kv, name+"=") {
+			env[i] = name + "=" + value
+			return env
+		}
+	}
+	return append(env, name+"="+value)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotRepository is the repository of the images bubble commits.
+const snapshotRepository = "bubble/snapshots"
+
+// commitContainer commits the filesystem of the container into an image
+// labeled as created by bubble.
+func commitContainer(container candidate, reference, comment string) (string, error) {
+	resp, err := container.host.client.ContainerCommit(context.Background(), container.ID, types.ContainerCommitOptions{
+		Reference: reference,
+		Comment:   comment,
+		Author:    "bubble",
+		Changes:   []string{fmt.Sprintf("LABEL %s=true", managedLabel)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not commit container id %s: %w", container.ID, err)
+	}
+	return resp.ID, nil
+}
+
+// snapshotVictim commits a container about to be deleted, tagged with the
+// cycle and its id.
+func snapshotVictim(container candidate, cycle int) (string, error) {
+	reference := fmt.Sprintf("%s:cycle-%06d-%s", snapshotRepository, cycle, shortID(container.ID))
+	if _, err := commitContainer(container, reference, fmt.Sprintf("snapshot of %s before its deletion", container.ID)); err != nil {
+		return "", err
+	}
+	return reference, nil
+}
+
+// snapshotReference is the image copies are created from in snapshot mode,
+// bubble/<image repository>:snapshot. Copies of snapshots are snapshotted
+// into the same reference.
+func snapshotReference(image string) string {
+	if strings.HasPrefix(image, "bubble/") && strings.HasSuffix(image, ":snapshot") {
+		return image
+	}
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return "bubble/" + strings.ToLower(image) + ":snapshot"
+}
+
+// snapshotSource commits the source of the plan once per target and host,
+// and returns the image its copies are created from.
+func (r *runner) snapshotSource(p plan) (string, error) {
+	key := p.source.host.name + " " + p.target.String()
+	if reference, ok := r.snapshots[key]; ok {
+		return reference, nil
+	}
+	reference := snapshotReference(p.source.Image)
+	if _, err := commitContainer(p.source, reference, fmt.Sprintf("snapshot of %s for its copies", p.source.ID)); err != nil {
+		return "", err
+	}
+	logrus.WithField("container", p.source.ID).WithField("host", p.source.host.name).WithField("image", reference).Info("snapshot source")
+	if r.snapshots == nil {
+		r.snapshots = map[string]string{}
+	}
+	r.snapshots[key] = reference
+	return reference, nil
+}
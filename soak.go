@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stats counts what happened during a run, for the soak test verdict.
+type stats struct {
+	cycles         int
+	failedCycles   int
+	probes         int
+	probeSuccesses int
+}
+
+func (s stats) probeSuccessRate() float64 {
+	if s.probes == 0 {
+		return 1
+	}
+	return float64(s.probeSuccesses) / float64(s.probes)
+}
+
+// probe checks the service behind the churned containers is still answering,
+// any 2xx or 3xx status is a success.
+func probe(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *runner) runProbe() {
+	if r.opts.probeURL == "" {
+		return
+	}
+	r.stats.probes++
+	if err := probe(r.opts.probeURL); err != nil {
+		registry.inc(metricProbesFailed)
+		logrus.WithError(err).WithField("url", r.opts.probeURL).Warn("probe failed")
+		return
+	}
+	r.stats.probeSuccesses++
+	registry.inc(metricProbesSuccess)
+}
+
+// verdict tells whether the run stayed within the failure thresholds.
+func (r *runner) verdict() bool {
+	if r.budget.exceeded() {
+		return false
+	}
+	if r.opts.maxFailedCycles >= 0 && r.stats.failedCycles > r.opts.maxFailedCycles {
+		return false
+	}
+	return r.stats.probeSuccessRate() >= r.opts.minProbeSuccess
+}
+
+func (r *runner) printSummary(pass bool) {
+	result := "PASS"
+	if !pass {
+		result = "FAIL"
+	}
+	fmt.Fprintf(r.report(), "duration: %v\n", time.Since(r.started).Round(time.Second))
+	fmt.Fprintf(r.report(), "cycles: %v, failed: %v (max %v)\n", r.stats.cycles, r.stats.failedCycles, r.opts.maxFailedCycles)
+	if r.opts.probeURL != "" {
+		fmt.Fprintf(r.report(), "probes: %v, success rate: %.2f%% (min %.2f%%)\n", r.stats.probes, 100*r.stats.probeSuccessRate(), 100*r.opts.minProbeSuccess)
+	}
+	if r.budget.limit.set {
+		fmt.Fprintf(r.report(), "operations: %v, failed: %v (budget %s)\n", r.budget.ops, r.budget.failures, r.budget.limit.String())
+	}
+	fmt.Fprintf(r.report(), "result: %s\n", result)
+}
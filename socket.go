@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// socketHost returns the docker host of the local daemon socket, a unix
+// socket path or a windows named pipe, given as a path or as an url.
+func socketHost(socket string) (string, error) {
+	switch {
+	case strings.HasPrefix(socket, "unix://"), strings.HasPrefix(socket, "npipe://"):
+		return socket, nil
+	case strings.HasPrefix(socket, `\\.\pipe\`), strings.HasPrefix(socket, "//./pipe/"):
+		return "npipe://" + filepath.ToSlash(socket), nil
+	case strings.Contains(socket, "://"):
+		return "", fmt.Errorf("invalid socket %q, expected a unix socket path or a named pipe", socket)
+	}
+	path, err := filepath.Abs(socket)
+	if err != nil {
+		return "", fmt.Errorf("invalid socket %q: %w", socket, err)
+	}
+	return "unix://" + path, nil
+}
+
+func checkSocket(o *options) error {
+	if o.socket == "" {
+		return nil
+	}
+	if len(o.hosts) > 0 {
+		return errors.New("--socket can not be used with --host, use --host unix:// or npipe:// instead")
+	}
+	_, err := socketHost(o.socket)
+	return err
+}
+
+// socketCandidates are the sockets of the usual docker setups, in order:
+// rootless docker, colima, rancher desktop, docker desktop and the default
+// one.
+func socketCandidates(home, runtimeDir string) []string {
+	candidates := []string{}
+	if runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "docker.sock"))
+	}
+	if home != "" {
+		candidates = append(candidates,
+			filepath.Join(home, ".colima", "default", "docker.sock"),
+			filepath.Join(home, ".colima", "docker.sock"),
+			filepath.Join(home, ".rd", "docker.sock"),
+			filepath.Join(home, ".docker", "run", "docker.sock"),
+			filepath.Join(home, ".docker", "desktop", "docker.sock"),
+		)
+	}
+	return append(candidates, "/var/run/docker.sock")
+}
+
+// detectSocket returns the first of the candidates which is a socket.
+func detectSocket(candidates []string) string {
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// localDaemon is the host of the daemon used when there is no --host:
+// --socket, else the one of DOCKER_HOST, else the first socket found among
+// the ones of the usual docker setups. An empty host leaves the docker
+// client default, e.g. the default named pipe on windows.
+func localDaemon(opts *options) string {
+	if opts.socket != "" {
+		host, _ := socketHost(opts.socket)
+		return host
+	}
+	if os.Getenv("DOCKER_HOST") != "" || runtime.GOOS == osWindows {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	socket := detectSocket(socketCandidates(home, os.Getenv("XDG_RUNTIME_DIR")))
+	if socket == "" {
+		return ""
+	}
+	logrus.WithField("socket", socket).Debug("docker socket detected")
+	return "unix://" + socket
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketHost(t *testing.T) {
+	tests := []struct {
+		socket string
+		want   string
+		err    bool
+	}{
+		{"/run/user/1000/docker.sock", "unix:///run/user/1000/docker.sock", false},
+		{"unix:///home/me/.colima/docker.sock", "unix:///home/me/.colima/docker.sock", false},
+		{"npipe:////./pipe/docker_engine", "npipe:////./pipe/docker_engine", false},
+		{"//./pipe/dockerDesktopLinuxEngine", "npipe:////./pipe/dockerDesktopLinuxEngine", false},
+		{"tcp://node1:2375", "", true},
+	}
+	for _, test := range tests {
+		got, err := socketHost(test.socket)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("socketHost(%q) = %q, %v, want %q", test.socket, got, err, test.want)
+		}
+	}
+}
+
+func TestDetectSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bubble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	file := filepath.Join(dir, "file.sock")
+	if err := ioutil.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		candidates []string
+		want       string
+	}{
+		{[]string{filepath.Join(dir, "missing.sock"), socket}, socket},
+		{[]string{file, socket}, socket},
+		{[]string{file}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := detectSocket(test.candidates); got != test.want {
+			t.Errorf("detectSocket(%v) = %q, want %q", test.candidates, got, test.want)
+		}
+	}
+}
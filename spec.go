@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+)
+
+// managedLabel marks the containers created by bubble.
+const managedLabel = "bubble.managed"
+
+// copySpec is exactly what is submitted to ContainerCreate for a copy,
+// followed by the connection to its extra networks.
+type copySpec struct {
+	Name             string
+	Config           *ac.Config
+	HostConfig       *ac.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+	ExtraEndpoints   map[string]*network.EndpointSettings `json:",omitempty"`
+}
+
+// sourceSpec is the spec of the source container as docker reports it.
+func sourceSpec(infos types.ContainerJSON) copySpec {
+	spec := copySpec{
+		Name:             strings.TrimPrefix(infos.Name, "/"),
+		Config:           infos.Config,
+		HostConfig:       infos.ContainerJSONBase.HostConfig,
+		NetworkingConfig: &network.NetworkingConfig{},
+	}
+	if infos.NetworkSettings != nil {
+		spec.NetworkingConfig.EndpointsConfig = infos.NetworkSettings.Networks
+	}
+	return spec
+}
+
+// clone returns a deep copy of the spec, so that it can be modified without
+// touching the source one.
+func (s copySpec) clone() (copySpec, error) {
+	var c copySpec
+	data, err := json.Marshal(s)
+	if err != nil {
+		return c, fmt.Errorf("could not encode spec: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("could not decode spec: %w", err)
+	}
+	return c, nil
+}
+
+// newCopySpec turns a clone of the source spec into the spec of a copy. The
+// number is only used to name the copies of compose services.
+func newCopySpec(source copySpec, sourceID string, t target, number int, opts *options) (copySpec, error) {
+	spec, err := source.clone()
+	if err != nil {
+		return spec, err
+	}
+	if spec.Config == nil {
+		return spec, errors.New("source has no config")
+	}
+	suffix := randomSuffix()
+	if spec.Config.Labels == nil {
+		spec.Config.Labels = map[string]string{}
+	}
+	spec.Name = copyName(source.Name, suffix, spec.Config.Labels[managedLabel] == "true")
+	spec.Config.Labels[managedLabel] = "true"
+	spec.Config.Labels[targetLabel] = t.String()
+	for key, value := range t.scope {
+		spec.Config.Labels[key] = value
+	}
+	if t.service != "" {
+		prepareCompose(&spec, t, number)
+	}
+	prepareNetworks(&spec, sourceID, suffix, t.service, opts)
+	if t.network != "" {
+		prepareNetworkScope(&spec, t.network)
+	}
+	if opts.isolatedNetwork {
+		if err := prepareIsolatedNetwork(&spec); err != nil {
+			return spec, err
+		}
+	}
+	if err := prepareHostname(&spec, hostnameData{
+		Name:   spec.Name,
+		Source: source.Name,
+		Suffix: suffix,
+		Image:  spec.Config.Image,
+	}, opts); err != nil {
+		return spec, err
+	}
+	prepareHealthcheck(spec.Config, opts)
+	if err := prepareHostConfig(spec.HostConfig, opts); err != nil {
+		return spec, fmt.Errorf("invalid host config: %w", err)
+	}
+	if err := prepareDeviceRequests(spec.HostConfig, opts); err != nil {
+		return spec, fmt.Errorf("invalid device requests: %w", err)
+	}
+	fuzzSpec(&spec, opts)
+	skewClock(&spec, opts)
+	if opts.override != nil {
+		if err := applyOverride(&spec, opts.override); err != nil {
+			return spec, fmt.Errorf("could not apply override: %w", err)
+		}
+	}
+	if opts.configPatch != nil {
+		if err := applyJSONPatch(&spec, opts.configPatch); err != nil {
+			return spec, fmt.Errorf("could not apply config patch: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+// specChange is a field which differs between two specs.
+type specChange struct {
+	Path string
+	From interface{}
+	To   interface{}
+}
+
+// diffSpec compares the json representations of both specs, which is what
+// the daemon receives, and returns the changed fields sorted by path.
+func diffSpec(from, to copySpec) ([]specChange, error) {
+	var a, b interface{}
+	for _, s := range []struct {
+		spec copySpec
+		out  *interface{}
+	}{{from, &a}, {to, &b}} {
+		data, err := json.Marshal(s.spec)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode spec: %w", err)
+		}
+		if err := json.Unmarshal(data, s.out); err != nil {
+			return nil, fmt.Errorf("could not decode spec: %w", err)
+		}
+	}
+	changes := diffValue("", a, b, nil)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func diffValue(path string, a, b interface{}, changes []specChange) []specChange {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if okA && okB {
+		for key, va := range ma {
+			changes = diffValue(path+"."+key, va, mb[key], changes)
+		}
+		for key, vb := range mb {
+			if _, ok := ma[key]; !ok {
+				changes = diffValue(path+"."+key, nil, vb, changes)
+			}
+		}
+		return changes
+	}
+	if isEmpty(a) && isEmpty(b) || reflect.DeepEqual(a, b) {
+		return changes
+	}
+	return append(changes, specChange{Path: path[1:], From: a, To: b})
+}
+
+// isEmpty reports whether v is the json encoding of a zero value, so that
+// null and empty fields are not reported as differences.
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// logDiff logs the fields of the copy which differ from its source.
+func logDiff(containerID string, from, to copySpec) {
+	changes, err := diffSpec(from, to)
+	if err != nil {
+		logrus.WithError(err).Warn("could not compute config diff")
+		return
+	}
+	logger := logrus.WithField("container", containerID)
+	if len(changes) == 0 {
+		logger.Info("copy config is identical to source")
+		return
+	}
+	for _, change := range changes {
+		from, to := redaction.value(change.From), redaction.value(change.To)
+		if redaction.sensitive(change.Path[strings.LastIndex(change.Path, ".")+1:]) {
+			from, to = redacted, redacted
+		}
+		logger.WithField("field", change.Path).
+			WithField("source", from).
+			WithField("copy", to).
+			Info("copy config differs from source")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// standby is a copy created but not started, ready to be started by a cycle.
+type standby struct {
+	id   string
+	spec copySpec
+}
+
+// standbyKey is the target and host a standby copy is ready for.
+type standbyKey struct {
+	target string
+	host   *host
+}
+
+// standbyPool keeps size stopped copies of each target ready on the hosts
+// copies of the target were created on, so that creating a copy only starts
+// one of them, the pool being replenished in the background.
+type standbyPool struct {
+	size    int
+	mu      sync.Mutex
+	ready   map[standbyKey][]standby
+	filling map[standbyKey]int
+	fills   sync.WaitGroup
+	closed  bool
+}
+
+func newStandbyPool(size int) *standbyPool {
+	return &standbyPool{size: size, ready: map[standbyKey][]standby{}, filling: map[standbyKey]int{}}
+}
+
+func checkStandby(o *options) error {
+	if o.standbyPool < 0 {
+		return fmt.Errorf("standby pool size must not be negative, got %v", o.standbyPool)
+	}
+	if o.standbyPool > 0 && o.backend != backendDocker {
+		return errors.New("standby pool requires the docker backend")
+	}
+	if o.standbyPool > 0 && o.fromSnapshot {
+		return errors.New("--standby-pool can not be used with --from-snapshot, snapshots only exist on the source host")
+	}
+	return nil
+}
+
+// take returns a copy of the target ready on the host, created from the
+// image. Ready copies of another image, e.g. after the target was deployed
+// again, are discarded. Without pool, there is never any ready copy.
+func (p *standbyPool) take(log *logrus.Entry, t target, h *host, image string) (standby, bool) {
+	if p == nil {
+		return standby{}, false
+	}
+	key := standbyKey{target: t.String(), host: h}
+	p.mu.Lock()
+	ready := p.ready[key]
+	stale := []standby{}
+	var found *standby
+	for len(ready) > 0 && found == nil {
+		s := ready[0]
+		ready = ready[1:]
+		if s.spec.Config.Image == image {
+			found = &s
+		} else {
+			stale = append(stale, s)
+		}
+	}
+	p.ready[key] = ready
+	p.mu.Unlock()
+	for _, s := range stale {
+		discardContainer(log, h, s.id, s.spec.Config.Labels[isolatedNetworkLabel])
+	}
+	if found == nil {
+		return standby{}, false
+	}
+	return *found, true
+}
+
+// replenish creates in the background the copies missing from the pool of
+// the target on every host, from the spec of the source.
+func (p *standbyPool) replenish(log *logrus.Entry, t target, hosts []*host, source copySpec, sourceID string, number int, opts *options) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for _, h := range uniqueHosts(hosts) {
+		key := standbyKey{target: t.String(), host: h}
+		missing := p.size - len(p.ready[key]) - p.filling[key]
+		if missing <= 0 {
+			continue
+		}
+		p.filling[key] += missing
+		p.fills.Add(1)
+		go func(key standbyKey, missing int) {
+			defer p.fills.Done()
+			for i := 0; i < missing; i++ {
+				s, err := createStandby(log, key.host, source, sourceID, t, number+i, opts)
+				p.mu.Lock()
+				p.filling[key]--
+				if err == nil {
+					p.ready[key] = append(p.ready[key], s)
+				}
+				p.mu.Unlock()
+				if err != nil {
+					log.WithError(err).WithField("host", key.host.name).Error("could not replenish standby pool")
+				}
+			}
+		}(key, missing)
+	}
+}
+
+// release waits for the copies being created and removes every ready copy.
+func (p *standbyPool) release(log *logrus.Entry) {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.fills.Wait()
+	for key, ready := range p.ready {
+		for _, s := range ready {
+			discardContainer(log, key.host, s.id, s.spec.Config.Labels[isolatedNetworkLabel])
+		}
+	}
+	p.ready = map[standbyKey][]standby{}
+}
+
+// createStandby creates a copy of the source on the host without starting it.
+func createStandby(log *logrus.Entry, h *host, source copySpec, sourceID string, t target, number int, opts *options) (standby, error) {
+	spec, err := newCopySpec(source, sourceID, t, number, opts)
+	if err != nil {
+		return standby{}, fmt.Errorf("could not prepare copy of container id %s: %w", sourceID, err)
+	}
+	if h.osType() == osWindows {
+		prepareWindows(&spec)
+	}
+	ports, err := prepareCreate(log, h, &spec, opts)
+	if err != nil {
+		return standby{}, err
+	}
+	id, err := createStopped(log, h, &spec, ports, opts)
+	if err != nil {
+		return standby{}, err
+	}
+	return standby{id: id, spec: spec}, nil
+}
+
+// startStandby starts a ready copy as a new copy, and discards it when it
+// fails to start.
+func startStandby(log *logrus.Entry, h *host, s standby, opts *options) (string, error) {
+	began := time.Now()
+	if err := startContainer(log, h, s.id, s.spec, opts); err != nil {
+		discardContainer(log, h, s.id, s.spec.Config.Labels[isolatedNetworkLabel])
+		return "", err
+	}
+	log.WithField("container", s.id).WithField("host", h.name).WithField("duration", time.Since(began)).Info("start standby copy")
+	return s.id, nil
+}
+
+func uniqueHosts(hosts []*host) []*host {
+	unique := []*host{}
+	for _, h := range hosts {
+		if !containsHost(unique, h) {
+			unique = append(unique, h)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+func TestStandbyTake(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	redis := target{image: "redis"}
+	for _, tc := range []struct {
+		name      string
+		ready     []standby
+		image     string
+		id        string
+		ok        bool
+		discarded []string
+		left      int
+	}{
+		{"empty", nil, "redis:6", "", false, nil, 0},
+		{"first", []standby{standbySpec("a", "redis:6"), standbySpec("b", "redis:6")}, "redis:6", "a", true, nil, 1},
+		{"stale", []standby{standbySpec("a", "redis:5"), standbySpec("b", "redis:6"), standbySpec("c", "redis:5")}, "redis:6", "b", true, []string{"a"}, 1},
+		{"all stale", []standby{standbySpec("a", "redis:5")}, "redis:6", "", false, []string{"a"}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			discarded := []string(nil)
+			h := testHost(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					mu.Lock()
+					discarded = append(discarded, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+					mu.Unlock()
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+			pool := newStandbyPool(2)
+			key := standbyKey{target: redis.String(), host: h}
+			pool.ready[key] = tc.ready
+			s, ok := pool.take(log, redis, h, tc.image)
+			if ok != tc.ok || s.id != tc.id {
+				t.Errorf("took %q %v, expected %q %v", s.id, ok, tc.id, tc.ok)
+			}
+			if strings.Join(discarded, ",") != strings.Join(tc.discarded, ",") {
+				t.Errorf("discarded %v, expected %v", discarded, tc.discarded)
+			}
+			if len(pool.ready[key]) != tc.left {
+				t.Errorf("%v ready copies left, expected %v", len(pool.ready[key]), tc.left)
+			}
+		})
+	}
+	var pool *standbyPool
+	if _, ok := pool.take(log, redis, nil, "redis"); ok {
+		t.Error("took a copy without pool")
+	}
+}
+
+func standbySpec(id, image string) standby {
+	return standby{id: id, spec: copySpec{Config: &ac.Config{Image: image, Labels: map[string]string{}}}}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// state is what bubble persists to its state file after every cycle, so
+// that the counters and the final reports of an experiment survive a
+// restart of bubble.
+type state struct {
+	// Counters are the values of the counter series.
+	Counters       map[string]float64 `json:"counters"`
+	Cycles         int                `json:"cycles"`
+	FailedCycles   int                `json:"failed_cycles"`
+	Probes         int                `json:"probes"`
+	ProbeSuccesses int                `json:"probe_successes"`
+	Operations     int                `json:"operations"`
+	FailedOps      int                `json:"failed_operations"`
+	ExitCodes      map[int]int        `json:"exit_codes"`
+	OOMKilled      int                `json:"oom_killed"`
+	// HostPorts are the host ports allocated to copies, by host name.
+	HostPorts map[string]map[int]string `json:"host_ports,omitempty"`
+}
+
+// loadState restores the counters, stats, error budget and exits of the
+// state file, when it exists.
+func (r *runner) loadState() error {
+	data, err := ioutil.ReadFile(r.opts.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read state file: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("could not decode state file %s: %w", r.opts.stateFile, err)
+	}
+	for series, v := range s.Counters {
+		registry.mu.Lock()
+		registry.values[series] = v
+		registry.mu.Unlock()
+	}
+	r.stats = stats{cycles: s.Cycles, failedCycles: s.FailedCycles, probes: s.Probes, probeSuccesses: s.ProbeSuccesses}
+	r.budget.ops, r.budget.failures = s.Operations, s.FailedOps
+	exits.mu.Lock()
+	for code, n := range s.ExitCodes {
+		exits.codes[code] = n
+	}
+	exits.oomKilled = s.OOMKilled
+	exits.mu.Unlock()
+	hostPorts.restore(s.HostPorts)
+	return nil
+}
+
+// saveState replaces the state file, through a temporary file so that a
+// crash while writing it does not lose the previous state.
+func (r *runner) saveState() error {
+	kinds := map[string]string{}
+	for _, desc := range metricDescs {
+		kinds[desc.name] = desc.kind
+	}
+	s := state{
+		Counters:       map[string]float64{},
+		Cycles:         r.stats.cycles,
+		FailedCycles:   r.stats.failedCycles,
+		Probes:         r.stats.probes,
+		ProbeSuccesses: r.stats.probeSuccesses,
+		Operations:     r.budget.ops,
+		FailedOps:      r.budget.failures,
+		ExitCodes:      map[int]int{},
+		HostPorts:      hostPorts.snapshot(),
+	}
+	for series, v := range registry.snapshot() {
+		if kinds[seriesName(series)] == counterKind {
+			s.Counters[series] = v
+		}
+	}
+	exits.mu.Lock()
+	for code, n := range exits.codes {
+		s.ExitCodes[code] = n
+	}
+	s.OOMKilled = exits.oomKilled
+	exits.mu.Unlock()
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("could not encode state: %w", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(r.opts.stateFile), filepath.Base(r.opts.stateFile)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+	if err := os.Rename(f.Name(), r.opts.stateFile); err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	opts := &options{stateFile: filepath.Join(t.TempDir(), "state.json")}
+	saved := &runner{opts: opts, stats: stats{cycles: 12, failedCycles: 2, probes: 10, probeSuccesses: 9}, budget: budget{ops: 40, failures: 3}}
+	registry.inc(metricCreated, label{"host", "state-test"})
+	if err := saved.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	registry.set(metricCreated, 0, label{"host", "state-test"})
+	restored := &runner{opts: opts}
+	if err := restored.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if restored.stats != saved.stats || restored.budget.ops != 40 || restored.budget.failures != 3 {
+		t.Errorf("got stats %+v and %v/%v operations, want %+v and 40/3", restored.stats, restored.budget.failures, restored.budget.ops, saved.stats)
+	}
+	if got := registry.snapshot()[series(metricCreated, label{"host", "state-test"})]; got != 1 {
+		t.Errorf("got restored counter %v, want 1", got)
+	}
+	missing := &runner{opts: &options{stateFile: filepath.Join(t.TempDir(), "missing.json")}}
+	if err := missing.loadState(); err != nil {
+		t.Errorf("missing state file: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ac "github.com/docker/docker/api/types/container"
+)
+
+// defaultStopTimeout is how long the daemon gives containers to stop
+// before killing them, unless they have a stop timeout of their own.
+const defaultStopTimeout = 10 * time.Second
+
+// stopSignals are the signals --stop-signal accepts by name.
+var stopSignals = map[string]bool{
+	"SIGABRT": true, "SIGALRM": true, "SIGHUP": true, "SIGINT": true, "SIGKILL": true,
+	"SIGPWR": true, "SIGQUIT": true, "SIGTERM": true, "SIGUSR1": true, "SIGUSR2": true, "SIGWINCH": true,
+}
+
+func checkStopSignal(signal string) error {
+	if signal == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n <= 0 || n > 64 {
+			return fmt.Errorf("invalid stop signal %v, expected 1 to 64", n)
+		}
+		return nil
+	}
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if !stopSignals[name] {
+		return fmt.Errorf("unknown stop signal %q", signal)
+	}
+	return nil
+}
+
+// stopContainer stops the container. Without override, the daemon sends it
+// its stop signal, the one of its config or of its image, SIGTERM by
+// default. With --stop-signal, the override is sent instead and the
+// container killed when still running after its stop timeout, as the daemon
+// does. Windows containers do not get signals and are always stopped by the
+// daemon.
+func stopContainer(h *host, id string, opts *options) error {
+	if opts.stopSignal == "" || h.osType() == osWindows {
+		return h.client.ContainerStop(context.Background(), id, stopTimeout(h))
+	}
+	timeout := defaultStopTimeout
+	if infos, err := inspections.inspect(h, id); err == nil && infos.Config != nil && infos.Config.StopTimeout != nil {
+		timeout = time.Duration(*infos.Config.StopTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	status, errs := h.client.ContainerWait(ctx, id, ac.WaitConditionNotRunning)
+	if err := h.client.ContainerKill(context.Background(), id, opts.stopSignal); err != nil {
+		return err
+	}
+	select {
+	case <-status:
+		return nil
+	case err := <-errs:
+		if ctx.Err() == nil {
+			return err
+		}
+	}
+	return h.client.ContainerKill(context.Background(), id, "SIGKILL")
+}
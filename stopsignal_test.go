@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	ac "github.com/docker/docker/api/types/container"
+)
+
+func TestCheckStopSignal(t *testing.T) {
+	tests := []struct {
+		signal string
+		ok     bool
+	}{
+		{"", true},
+		{"SIGQUIT", true},
+		{"quit", true},
+		{"3", true},
+		{"0", false},
+		{"65", false},
+		{"SIGFOO", false},
+	}
+	for _, test := range tests {
+		if err := checkStopSignal(test.signal); (err == nil) != test.ok {
+			t.Errorf("checkStopSignal(%q) = %v, want ok %v", test.signal, err, test.ok)
+		}
+	}
+}
+
+func TestStopContainer(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name    string
+		signal  string
+		ostype  string
+		stopped bool
+		want    string
+	}{
+		{"own stop signal", "", "linux", true, "stop victim"},
+		{"override", "SIGQUIT", "linux", true, "inspect victim, wait victim, kill SIGQUIT victim"},
+		{"override killed after stop timeout", "SIGQUIT", "linux", false, "inspect victim, wait victim, kill SIGQUIT victim, kill SIGKILL victim"},
+		{"windows", "SIGQUIT", osWindows, true, "stop victim"},
+	}
+	for _, test := range tests {
+		m := &mockClient{
+			InspectFunc: func(id string) (types.ContainerJSON, error) {
+				return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: id}, Config: &ac.Config{StopTimeout: &zero}}, nil
+			},
+		}
+		if !test.stopped {
+			m.WaitFunc = func(id string, condition ac.WaitCondition) (ac.ContainerWaitOKBody, error) {
+				return ac.ContainerWaitOKBody{}, context.DeadlineExceeded
+			}
+		}
+		h := mockHost(m)
+		h.ostype = test.ostype
+		if err := stopContainer(h, "victim", &options{stopSignal: test.signal}); err != nil {
+			t.Errorf("%s: stopContainer = %v", test.name, err)
+		}
+		if got := m.Calls(); got != test.want {
+			t.Errorf("%s: calls = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
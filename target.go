@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// target is an image, or a compose service, whose containers are churned,
+// with its weight among the other targets.
+type target struct {
+	image   string
+	project string
+	service string
+	weight  int
+	// scope are the labels the containers of the target must have, and
+	// copies are stamped with.
+	scope map[string]string
+	// network is the network the containers of the target must be attached
+	// to, and copies are only attached to.
+	network string
+}
+
+func (t target) String() string {
+	s := t.image
+	if t.service != "" {
+		s = t.project + "/" + t.service
+	}
+	if len(t.scope) > 0 {
+		s += "[" + formatScope(t.scope) + "]"
+	}
+	if t.network != "" {
+		s += " on " + t.network
+	}
+	return s
+}
+
+// targetLabel holds the target of a copy, so that copies created from another
+// image than the one of the target, e.g. snapshots, still belong to it.
+const targetLabel = "bubble.target"
+
+func (t target) matches(container types.Container) bool {
+	for key, value := range t.scope {
+		if container.Labels[key] != value {
+			return false
+		}
+	}
+	if t.network != "" && !attached(container, t.network) {
+		return false
+	}
+	if container.Labels[targetLabel] == t.String() {
+		return true
+	}
+	if t.service != "" {
+		return isComposeContainer(container, t.project, t.service)
+	}
+	return container.Image == t.image
+}
+
+// parseTarget parses an image optionally followed by its weight, e.g.
+// redis:6=3. Images without weight weigh 1.
+func parseTarget(s string) (target, error) {
+	t := target{image: s, weight: 1}
+	if i := strings.LastIndex(s, "="); i >= 0 {
+		weight, err := strconv.Atoi(s[i+1:])
+		if err != nil || weight <= 0 {
+			return t, fmt.Errorf("invalid weight in image %q, expected a positive integer", s)
+		}
+		t.image, t.weight = s[:i], weight
+	}
+	if t.image == "" {
+		return t, fmt.Errorf("invalid image %q", s)
+	}
+	return t, nil
+}
+
+// pickTarget chooses the target churned by a cycle, the heavier a target the
+// more often it is chosen.
+func pickTarget(targets []target) target {
+	total := 0
+	for _, t := range targets {
+		total += t.weight
+	}
+	r := rand.Intn(total)
+	for _, t := range targets {
+		if r < t.weight {
+			return t
+		}
+		r -= t.weight
+	}
+	return targets[len(targets)-1]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sirupsen/logrus"
+)
+
+// loadTargets reads a targets file, written like a config file with a single
+// image key listing the images and their weights.
+func loadTargets(path string) ([]target, error) {
+	entries, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	targets := []target{}
+	for _, entry := range entries {
+		if entry.key != "image" {
+			return nil, fmt.Errorf("%s:%d: unknown key %q, expected image", path, entry.line, entry.key)
+		}
+		for _, value := range entry.values {
+			t, err := parseTarget(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, entry.line, err)
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// targetsWatcher reloads the targets file whenever its content changes. It
+// is polled on its own ticker, independently of the cycle frequency, and
+// compares the hash of the content since editors and other systems do not
+// always change the modification time.
+type targetsWatcher struct {
+	path string
+	sum  []byte
+}
+
+// reload returns the new targets and true when the file changed since the
+// last call.
+func (w *targetsWatcher) reload() ([]target, bool, error) {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read targets file %s: %w", w.path, err)
+	}
+	sum := sha256.Sum256(data)
+	if bytes.Equal(sum[:], w.sum) {
+		return nil, false, nil
+	}
+	targets, err := loadTargets(w.path)
+	if err != nil {
+		return nil, false, err
+	}
+	w.sum = sum[:]
+	return targets, true, nil
+}
+
+// reloadTargets replaces the targets by the ones of the targets file when it
+// changed, keeping the current ones when it can not be read.
+func (r *runner) reloadTargets() {
+	if r.targets == nil {
+		return
+	}
+	targets, changed, err := r.targets.reload()
+	if err != nil {
+		logrus.WithError(err).Error("could not reload targets, keeping the current ones")
+		return
+	}
+	if !changed {
+		return
+	}
+	base := targetsOf(r.opts.images)
+	if r.opts.service != "" {
+		t, _ := r.opts.composeTarget()
+		base = append(base, t)
+	}
+	r.opts.targets = networkScoped(scoped(append(base, targets...), r.opts.scope), r.opts.networkScope)
+	logrus.WithField("targets", len(r.opts.targets)).Info("targets reloaded")
+}
+
+// targetsOf parses images already checked by the options.
+func targetsOf(images []string) []target {
+	targets := []target{}
+	for _, image := range images {
+		t, _ := parseTarget(image)
+		targets = append(targets, t)
+	}
+	return targets
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Steps of the cycles whose duration is measured.
+const (
+	stepList    = "list"
+	stepInspect = "inspect"
+	stepCreate  = "create"
+	stepStart   = "start"
+	stepStop    = "stop"
+	stepWait    = "wait"
+	stepRemove  = "remove"
+)
+
+var steps = []string{stepList, stepInspect, stepCreate, stepStart, stepStop, stepWait, stepRemove}
+
+// stepTimings sums the durations of the steps of the running cycle.
+type stepTimings struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+var timings = &stepTimings{durations: map[string]time.Duration{}}
+
+// timeStep records the duration of a docker api call of the step which
+// began at began, in the step histogram and in the timings of the cycle.
+func timeStep(step string, began time.Time) {
+	d := time.Since(began)
+	registry.observe(metricStepDuration, d.Seconds(), label{"step", step})
+	timings.mu.Lock()
+	timings.durations[step] += d
+	timings.mu.Unlock()
+}
+
+// reset returns the durations of the steps since the last reset.
+func (t *stepTimings) reset() map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	durations := t.durations
+	t.durations = map[string]time.Duration{}
+	return durations
+}
+
+// logTimings logs how long the cycle spent on each step, to diagnose slow
+// daemons.
+func logTimings(log *logrus.Entry, durations map[string]time.Duration) {
+	for _, step := range steps {
+		log = log.WithField(step, durations[step].Round(time.Millisecond))
+	}
+	log.Debug("cycle timing")
+}
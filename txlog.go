@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Actions of the transaction log.
+const (
+	actionCreate = "create"
+	actionRemove = "remove"
+	actionUndo   = "undo"
+)
+
+// transaction is a line of the transaction log: a container a cycle created
+// or removed, with the spec of removed ones to recreate them, or the undo
+// of a cycle.
+type transaction struct {
+	Run    string    `json:"run"`
+	Cycle  int       `json:"cycle"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Host   string    `json:"host,omitempty"`
+	ID     string    `json:"id,omitempty"`
+	Spec   *copySpec `json:"spec,omitempty"`
+}
+
+// key identifies the cycle of the transaction across runs.
+func (t transaction) key() string {
+	return fmt.Sprintf("%s/%d", t.Run, t.Cycle)
+}
+
+// txLog appends the transactions of the cycles of a run to a file, as json
+// lines.
+type txLog struct {
+	path string
+	run  string
+}
+
+func newTxLog(path string, started time.Time) *txLog {
+	return &txLog{path: path, run: started.UTC().Format(time.RFC3339Nano)}
+}
+
+func (l *txLog) append(transactions ...transaction) error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open transaction log: %w", err)
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, t := range transactions {
+		if err := encoder.Encode(t); err != nil {
+			return fmt.Errorf("could not write transaction log: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordCycle logs the copies created and the victims removed by the
+// cycle, specs being the specs of the victims by id.
+func (l *txLog) recordCycle(cycle int, copies []created, removed []candidate, specs map[string]copySpec) error {
+	now := time.Now()
+	transactions := []transaction{}
+	for _, c := range copies {
+		transactions = append(transactions, transaction{Run: l.run, Cycle: cycle, Time: now, Action: actionCreate, Host: c.host.name, ID: c.id})
+	}
+	for _, victim := range removed {
+		spec := specs[victim.ID]
+		transactions = append(transactions, transaction{Run: l.run, Cycle: cycle, Time: now, Action: actionRemove, Host: victim.host.name, ID: victim.ID, Spec: &spec})
+	}
+	if len(transactions) == 0 {
+		return nil
+	}
+	return l.append(transactions...)
+}
+
+// victimSpecs inspects the victims for the spec they can be recreated from.
+func victimSpecs(victims []candidate) (map[string]copySpec, error) {
+	specs := map[string]copySpec{}
+	for _, victim := range victims {
+		began := time.Now()
+		infos, err := victim.host.client.ContainerInspect(context.Background(), victim.ID)
+		timeStep(stepInspect, began)
+		if err != nil {
+			return nil, fmt.Errorf("could not inspect container id %s: %w", victim.ID, err)
+		}
+		specs[victim.ID] = sourceSpec(infos)
+	}
+	return specs, nil
+}
+
+// readTxLog reads every transaction of the log.
+func readTxLog(path string) ([]transaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open transaction log: %w", err)
+	}
+	defer f.Close()
+	transactions := []transaction{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var t transaction
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid transaction: %w", path, n, err)
+		}
+		transactions = append(transactions, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read transaction log: %w", err)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// undo reverts the last cycles of the transaction log which were not
+// already undone: it removes the copies they created and recreates the
+// containers they removed from their saved specs.
+func undo(hosts []*host, opts *options) error {
+	transactions, err := readTxLog(opts.transactionLog)
+	if err != nil {
+		return err
+	}
+	undone := map[string]bool{}
+	for _, t := range transactions {
+		if t.Action == actionUndo {
+			undone[t.key()] = true
+		}
+	}
+	cycles := []string{}
+	byCycle := map[string][]transaction{}
+	for _, t := range transactions {
+		if t.Action == actionUndo || undone[t.key()] {
+			continue
+		}
+		if _, ok := byCycle[t.key()]; !ok {
+			cycles = append(cycles, t.key())
+		}
+		byCycle[t.key()] = append(byCycle[t.key()], t)
+	}
+	if len(cycles) > opts.undoCycles {
+		cycles = cycles[len(cycles)-opts.undoCycles:]
+	}
+	if len(cycles) == 0 {
+		logrus.Info("nothing to undo")
+		return nil
+	}
+	byName := map[string]*host{}
+	for _, h := range hosts {
+		byName[h.name] = h
+	}
+	log := &txLog{path: opts.transactionLog}
+	for i := len(cycles) - 1; i >= 0; i-- {
+		cycle := byCycle[cycles[i]]
+		for j := len(cycle) - 1; j >= 0; j-- {
+			if err := undoTransaction(cycle[j], byName, opts); err != nil {
+				return err
+			}
+		}
+		if err := log.append(transaction{Run: cycle[0].Run, Cycle: cycle[0].Cycle, Time: time.Now(), Action: actionUndo}); err != nil {
+			return err
+		}
+		logrus.WithField("run", cycle[0].Run).WithField("cycle", cycle[0].Cycle).Info("cycle undone")
+	}
+	return nil
+}
+
+func undoTransaction(t transaction, hosts map[string]*host, opts *options) error {
+	h, ok := hosts[t.Host]
+	if !ok {
+		return fmt.Errorf("host %s of container id %s is not among the hosts", t.Host, t.ID)
+	}
+	switch t.Action {
+	case actionCreate:
+		err := h.client.ContainerRemove(context.Background(), t.ID, types.ContainerRemoveOptions{Force: true})
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove container id %s: %w", t.ID, err)
+		}
+		logrus.WithField("container", t.ID).WithField("host", h.name).Info("remove copy")
+	case actionRemove:
+		if t.Spec == nil || t.Spec.Config == nil {
+			return fmt.Errorf("no spec saved for container id %s", t.ID)
+		}
+		if _, err := recreateContainer(logrus.NewEntry(logrus.StandardLogger()), h, t.ID, *t.Spec, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recreateContainer creates again, with its name, a removed container from
+// the spec it was inspected with, and returns the id of the new one.
+func recreateContainer(log *logrus.Entry, h *host, removedID string, spec copySpec, opts *options) (string, error) {
+	// the endpoints are reported as docker inspects them, they are submitted
+	// like for a copy, keeping their aliases.
+	prepareNetworks(&spec, removedID, "", "", &options{keepAliases: true})
+	id, err := createContainer(log, h, spec, opts)
+	if err != nil {
+		return "", err
+	}
+	log.WithField("container", id).WithField("host", h.name).WithField("name", spec.Name).Info("recreate container")
+	return id, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validate checks the options, the docker connectivity and the candidates,
+// then prints the plan of the next cycle without executing it.
+func validate(hosts []*host, opts *options) error {
+	fmt.Printf("frequency: %v\nratio: %s\n", opts.freq, opts.ratio.String())
+	for _, s := range opts.schedule {
+		fmt.Printf("schedule: %s\n", s)
+	}
+
+	for _, h := range hosts {
+		ping, err := checkAPIVersion(h)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("docker %s: reachable, api version %s, daemon supports up to %s\n", h.name, h.client.ClientVersion(), ping.APIVersion)
+	}
+
+	eligible, err := eligibleHosts(hosts, opts.constraints)
+	if err != nil {
+		return err
+	}
+	for _, target := range opts.targets {
+		candidates, err := listCandidates(hosts, target, opts.states)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("no %s container matches %s", strings.Join(opts.states, ", "), target)
+		}
+		fmt.Printf("target %s: weight %v, %v candidates\n", target, target.weight, len(candidates))
+
+		p, err := makePlan(target, candidates, eligible, ratioAt(opts, time.Now()).resolve(len(candidates)), opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("plan: every %v, %s\n", opts.freq, p)
+	}
+	return nil
+}
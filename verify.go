@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// verifiedDigests are the image digests whose signature was already
+// verified, each digest is checked once.
+var verifiedDigests = struct {
+	sync.Mutex
+	digests map[string]bool
+}{digests: map[string]bool{}}
+
+// imageDigest returns the registry digest, repository@sha256:..., of the
+// image of the source, preferring the repository of the name it runs as.
+func imageDigest(source *host, imageID, name string) (string, error) {
+	inspect, _, err := source.client.ImageInspectWithRaw(context.Background(), imageID)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect image %s: %w", imageID, err)
+	}
+	if len(inspect.RepoDigests) == 0 {
+		return "", fmt.Errorf("image %s has no registry digest, it can not be signed", name)
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if _, ok := named.(reference.Canonical); err == nil && ok {
+		return name, nil
+	}
+	if err == nil {
+		for _, digest := range inspect.RepoDigests {
+			if d, err := reference.ParseNormalizedNamed(digest); err == nil && d.Name() == named.Name() {
+				return digest, nil
+			}
+		}
+	}
+	return inspect.RepoDigests[0], nil
+}
+
+// verifySignature refuses the image of the source unless its digest is
+// signed with the cosign key, verified by the cosign binary.
+func verifySignature(source *host, imageID, name string, opts *options) error {
+	digest, err := imageDigest(source, imageID, name)
+	if err != nil {
+		return err
+	}
+	verifiedDigests.Lock()
+	verified := verifiedDigests.digests[digest]
+	verifiedDigests.Unlock()
+	if verified {
+		return nil
+	}
+	cmd := exec.Command("cosign", "verify", "--key", opts.verifyKey, digest)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not verify signature of image %s: %w: %s", digest, err, strings.TrimSpace(stderr.String()))
+	}
+	verifiedDigests.Lock()
+	verifiedDigests.digests[digest] = true
+	verifiedDigests.Unlock()
+	logrus.WithField("image", digest).Info("image signature verified")
+	return nil
+}
+
+// verifyImage verifies the signature of the image a container is about to be
+// created from, as the target host has it, pulling it first when the host
+// does not, so that no container is created from an unsigned image whatever
+// the command creating it.
+func verifyImage(target *host, image string, opts *options) error {
+	_, _, err := target.client.ImageInspectWithRaw(context.Background(), image)
+	if client.IsErrNotFound(err) {
+		err = pullImage(target, image, "", opts)
+	}
+	if err != nil {
+		return fmt.Errorf("could not inspect image %s on host %s: %w", image, target.name, err)
+	}
+	return verifySignature(target, image, image, opts)
+}
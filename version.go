@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/docker/docker/api"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.date=2021-01-01T00:00:00Z".
+var (
+	version = ""
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// buildVersion returns the version set at build time, or the module version
+// when bubble was installed with go install.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// printVersion prints the build information and the docker api version used
+// with each host.
+func printVersion(w io.Writer, hosts []*host) {
+	fmt.Fprintf(w, "version: %s\ncommit: %s\nbuilt: %s\ngo: %s %s/%s\n", buildVersion(), commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "docker api: up to %s\n", api.DefaultVersion)
+	for _, h := range hosts {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ping, err := h.client.Ping(ctx)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(w, "docker %s: unreachable\n", h.name)
+			continue
+		}
+		fmt.Fprintf(w, "docker %s: api version %s, daemon supports up to %s\n", h.name, h.client.ClientVersion(), ping.APIVersion)
+	}
+}
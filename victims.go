@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	victimsUniform = "uniform"
+	victimsAge     = "age"
+)
+
+func checkVictimStrategy(strategy string) error {
+	if strategy != victimsUniform && strategy != victimsAge {
+		return fmt.Errorf("unknown victim strategy %q, expected %s or %s", strategy, victimsUniform, victimsAge)
+	}
+	return nil
+}
+
+// pickVictims draws n distinct victims among candidates: uniformly, or with
+// a probability proportional to their age so that old containers die more
+// often, giving a realistic lifetime distribution.
+func pickVictims(candidates []candidate, n int, strategy string, now time.Time) []candidate {
+	victims := []candidate{}
+	if strategy != victimsAge {
+		for _, i := range rand.Perm(len(candidates))[:n] {
+			victims = append(victims, candidates[i])
+		}
+		return victims
+	}
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	for i, c := range candidates {
+		// one second more so that containers created this very second can
+		// still be drawn.
+		weights[i] = now.Sub(time.Unix(c.Created, 0)).Seconds() + 1
+		if weights[i] < 1 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+	for len(victims) < n {
+		r := rand.Float64() * total
+		i := 0
+		for ; i < len(weights)-1; i++ {
+			if weights[i] > 0 && r < weights[i] {
+				break
+			}
+			r -= weights[i]
+		}
+		for weights[i] == 0 {
+			// rounding made r land after the last candidate left.
+			i--
+		}
+		victims = append(victims, candidates[i])
+		total -= weights[i]
+		weights[i] = 0
+	}
+	return victims
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func ageCandidates(now time.Time, ages ...time.Duration) []candidate {
+	candidates := []candidate{}
+	for i, age := range ages {
+		candidates = append(candidates, candidate{Container: types.Container{ID: string(rune('a' + i)), Created: now.Add(-age).Unix()}})
+	}
+	return candidates
+}
+
+func TestPickVictimsDistinct(t *testing.T) {
+	now := time.Now()
+	candidates := ageCandidates(now, 0, time.Second, time.Minute, time.Hour, 24*time.Hour)
+	for _, strategy := range []string{victimsUniform, victimsAge} {
+		for n := 0; n <= len(candidates); n++ {
+			for i := 0; i < 100; i++ {
+				victims := pickVictims(candidates, n, strategy, now)
+				if len(victims) != n {
+					t.Fatalf("%s: got %v victims, want %v", strategy, len(victims), n)
+				}
+				seen := map[string]bool{}
+				for _, v := range victims {
+					if seen[v.ID] {
+						t.Fatalf("%s: victim %s drawn twice", strategy, v.ID)
+					}
+					seen[v.ID] = true
+				}
+			}
+		}
+	}
+}
+
+func TestPickVictimsAge(t *testing.T) {
+	rand.Seed(1)
+	now := time.Now()
+	candidates := ageCandidates(now, time.Second, 1000*time.Hour)
+	old := 0
+	for i := 0; i < 1000; i++ {
+		if pickVictims(candidates, 1, victimsAge, now)[0].ID == "b" {
+			old++
+		}
+	}
+	if old < 990 {
+		t.Fatalf("old container drawn %v times out of 1000, expected nearly always", old)
+	}
+}
+
+func TestPickVictimsFuture(t *testing.T) {
+	now := time.Now()
+	// clocks of hosts may be ahead of the one of bubble.
+	candidates := ageCandidates(now, -time.Hour, -time.Minute)
+	victims := pickVictims(candidates, 2, victimsAge, now)
+	if len(victims) != 2 || victims[0].ID == victims[1].ID {
+		t.Fatalf("got victims %v", victims)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ac "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+)
+
+// Conditions removed containers are waited for.
+const (
+	waitNotRunning = "not-running"
+	waitRemoved    = "removed"
+)
+
+func checkWait(opts *options) error {
+	if opts.waitCondition != waitNotRunning && opts.waitCondition != waitRemoved {
+		return fmt.Errorf("unknown wait condition %q, expected %s or %s", opts.waitCondition, waitNotRunning, waitRemoved)
+	}
+	if opts.waitTimeout <= 0 {
+		return fmt.Errorf("wait timeout must be positive, got %v", opts.waitTimeout)
+	}
+	if opts.waitRetries < 0 {
+		return fmt.Errorf("wait retries must not be negative, got %v", opts.waitRetries)
+	}
+	return nil
+}
+
+// waiter waits for a container to reach a condition. The wait is
+// registered by startWait, before what makes the container reach the
+// condition, e.g. its removal, and awaited by wait.
+type waiter struct {
+	log       *logrus.Entry
+	host      *host
+	id        string
+	condition ac.WaitCondition
+	timeout   time.Duration
+	retries   int
+
+	cancel context.CancelFunc
+	status <-chan ac.ContainerWaitOKBody
+	errs   <-chan error
+}
+
+func startWait(log *logrus.Entry, h *host, id string, condition string, opts *options) *waiter {
+	w := &waiter{log: log, host: h, id: id, condition: ac.WaitConditionNotRunning, timeout: opts.waitTimeout, retries: opts.waitRetries}
+	if condition == waitRemoved {
+		w.condition = ac.WaitConditionRemoved
+	}
+	w.register()
+	return w
+}
+
+func (w *waiter) register() {
+	var ctx context.Context
+	ctx, w.cancel = context.WithTimeout(context.Background(), w.timeout)
+	w.status, w.errs = w.host.client.ContainerWait(ctx, w.id, w.condition)
+}
+
+// wait waits for the condition, registering the wait again when it fails,
+// e.g. because the connection to the daemon dropped, up to the retries. A
+// container which does not exist anymore has reached both conditions.
+func (w *waiter) wait() error {
+	defer timeStep(stepWait, time.Now())
+	for attempt := 0; ; attempt++ {
+		var err error
+		select {
+		case status := <-w.status:
+			w.cancel()
+			if status.Error != nil && status.Error.Message != "" {
+				return fmt.Errorf("could not wait for container id %s: %s", w.id, status.Error.Message)
+			}
+			return nil
+		case err = <-w.errs:
+			w.cancel()
+		}
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("not %s after %v", w.condition, w.timeout)
+		}
+		if attempt >= w.retries {
+			return fmt.Errorf("could not wait for container id %s: %w", w.id, err)
+		}
+		w.log.WithError(err).WithField("container", w.id).WithField("host", w.host.name).Warn("could not wait for container, retrying")
+		w.register()
+	}
+}
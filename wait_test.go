@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func testHost(t *testing.T, handler http.HandlerFunc) *host {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion("1.41"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &host{name: "test", client: c}
+}
+
+func TestWait(t *testing.T) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	opts := &options{waitTimeout: 100 * time.Millisecond, waitRetries: 2}
+	var calls int32
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		ok      bool
+		calls   int32
+	}{
+		{"exited", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Write([]byte(`{"StatusCode":137}`))
+		}, true, 1},
+		{"gone", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+		}, true, 1},
+		{"hanging", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-r.Context().Done()
+		}, false, 3},
+		{"dropped then exited", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"StatusCode":0}`))
+		}, true, 2},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		h := testHost(t, tc.handler)
+		err := startWait(log, h, "x", waitNotRunning, opts).wait()
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: got error %v, want ok %v", tc.name, err, tc.ok)
+		}
+		if got := atomic.LoadInt32(&calls); got != tc.calls {
+			t.Errorf("%s: got %v waits, want %v", tc.name, got, tc.calls)
+		}
+	}
+}
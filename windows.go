@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const osWindows = "windows"
+
+// windowsStopTimeout is how long windows containers have to stop before
+// being killed, they do not get signals and shut down slower than linux
+// ones.
+const windowsStopTimeout = 30 * time.Second
+
+// osType returns the operating system of the daemon, defaulting to linux
+// when it can not be known.
+func (h *host) osType() string {
+	if err := h.fetchPlatform(); err != nil {
+		logrus.WithError(err).WithField("host", h.name).Debug("could not get operating system, linux assumed")
+		return "linux"
+	}
+	return h.ostype
+}
+
+// shellCmd runs the command with the shell of containers of the host.
+func shellCmd(h *host, cmd string) []string {
+	if h.osType() == osWindows {
+		return []string{"cmd", "/S", "/C", cmd}
+	}
+	return []string{"/bin/sh", "-c", cmd}
+}
+
+// stopTimeout is the stop timeout of containers of the host, nil for the
+// daemon default.
+func stopTimeout(h *host) *time.Duration {
+	if h.osType() == osWindows {
+		timeout := windowsStopTimeout
+		return &timeout
+	}
+	return nil
+}
+
+// prepareWindows drops the linux only fields from a copy created on a
+// windows daemon, which rejects or ignores them, and its stop signal as
+// windows containers do not get signals.
+func prepareWindows(spec *copySpec) {
+	spec.Config.StopSignal = ""
+	hc := spec.HostConfig
+	if hc == nil {
+		return
+	}
+	hc.CapAdd, hc.CapDrop = nil, nil
+	hc.Sysctls, hc.Tmpfs = nil, nil
+	hc.Privileged, hc.ReadonlyRootfs = false, false
+	hc.PidMode, hc.IpcMode, hc.UTSMode, hc.UsernsMode, hc.Cgroup = "", "", "", "", ""
+	hc.OomScoreAdj, hc.ShmSize = 0, 0
+	securityOpt := []string{}
+	for _, opt := range hc.SecurityOpt {
+		// credential specs are the only windows security options.
+		if strings.HasPrefix(opt, "credentialspec=") {
+			securityOpt = append(securityOpt, opt)
+		}
+	}
+	hc.SecurityOpt = securityOpt
+	r := &hc.Resources
+	r.CgroupParent = ""
+	r.BlkioWeight, r.BlkioWeightDevice = 0, nil
+	r.BlkioDeviceReadBps, r.BlkioDeviceWriteBps, r.BlkioDeviceReadIOps, r.BlkioDeviceWriteIOps = nil, nil, nil, nil
+	r.CPUPeriod, r.CPUQuota, r.CPURealtimePeriod, r.CPURealtimeRuntime = 0, 0, 0, 0
+	r.CpusetMems, r.DeviceCgroupRules = "", nil
+	r.KernelMemory, r.KernelMemoryTCP, r.MemoryReservation, r.MemorySwap = 0, 0, 0, 0
+	r.MemorySwappiness, r.OomKillDisable, r.PidsLimit, r.Ulimits = nil, nil, nil, nil
+}